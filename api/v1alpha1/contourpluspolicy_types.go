@@ -0,0 +1,207 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyName is the only name of ContourPlusPolicy and ClusterContourPlusPolicy honored by contour-plus
+const PolicyName = "default"
+
+// ContourPlusPolicySpec overrides the defaults of contour-plus for the HTTPProxies in the namespace.
+// Unset fields inherit the defaults given by the command-line flags.
+type ContourPlusPolicySpec struct {
+	// DefaultIssuerName is the name of the issuer used for HTTPProxies without issuer annotations.
+	// +optional
+	DefaultIssuerName string `json:"defaultIssuerName,omitempty"`
+
+	// DefaultIssuerKind is the kind of the issuer used for HTTPProxies without issuer annotations.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +optional
+	DefaultIssuerKind string `json:"defaultIssuerKind,omitempty"`
+
+	// DefaultDelegatedDomain is the domain to which DNS-01 validation is delegated.
+	// +optional
+	DefaultDelegatedDomain string `json:"defaultDelegatedDomain,omitempty"`
+
+	// AllowedDelegatedDomains is the list of domains that HTTPProxies can choose with the delegated-domain annotation.
+	// An entry may be a wildcard such as "*.acme.example.com" or a regular expression enclosed in slashes.
+	// +optional
+	AllowedDelegatedDomains []string `json:"allowedDelegatedDomains,omitempty"`
+
+	// CSRRevisionLimit is the maximum number of CertificateRequests kept for a Certificate. 0 keeps all of them.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CSRRevisionLimit *int32 `json:"csrRevisionLimit,omitempty"`
+
+	// PropagatedAnnotations is the list of annotation keys propagated from HTTPProxies to the generated resources.
+	// +optional
+	PropagatedAnnotations []string `json:"propagatedAnnotations,omitempty"`
+
+	// PropagatedLabels is the list of label keys propagated from HTTPProxies to the generated resources.
+	// +optional
+	PropagatedLabels []string `json:"propagatedLabels,omitempty"`
+
+	// DNSRecordTTL is the TTL of DNS records.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	DNSRecordTTL *int32 `json:"dnsRecordTTL,omitempty"`
+}
+
+// ContourPlusPolicyStatus reports the policy in effect for the namespace.
+type ContourPlusPolicyStatus struct {
+	// ObservedGeneration is the generation of the spec reflected in the status.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Effective is the policy in effect after applying the defaults and the guardrails of ClusterContourPlusPolicy.
+	// +optional
+	Effective *EffectivePolicy `json:"effective,omitempty"`
+
+	// Conditions represent the latest observations of the policy.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// EffectivePolicy is the set of defaults in effect for the HTTPProxies in a namespace.
+type EffectivePolicy struct {
+	DefaultIssuerName  string `json:"defaultIssuerName,omitempty"`
+	DefaultIssuerKind  string `json:"defaultIssuerKind,omitempty"`
+	DefaultIssuerGroup string `json:"defaultIssuerGroup,omitempty"`
+
+	DefaultDelegatedDomain  string   `json:"defaultDelegatedDomain,omitempty"`
+	AllowedDelegatedDomains []string `json:"allowedDelegatedDomains,omitempty"`
+
+	CSRRevisionLimit int32 `json:"csrRevisionLimit"`
+
+	PropagatedAnnotations []string `json:"propagatedAnnotations,omitempty"`
+	PropagatedLabels      []string `json:"propagatedLabels,omitempty"`
+
+	DNSRecordTTL int32 `json:"dnsRecordTTL"`
+}
+
+// ContourPlusPolicyConditionAccepted is the condition type reporting if the spec is accepted as is.
+// It is False when some fields are adjusted to satisfy the guardrails.
+const ContourPlusPolicyConditionAccepted = "Accepted"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="name must be default"
+//+kubebuilder:printcolumn:name="ACCEPTED",type="string",JSONPath=".status.conditions[?(@.type=='Accepted')].status"
+//+kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ContourPlusPolicy overrides the defaults of contour-plus for the namespace.
+// Only the one named "default" is honored.
+type ContourPlusPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ContourPlusPolicySpec   `json:"spec,omitempty"`
+	Status ContourPlusPolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ContourPlusPolicyList contains a list of ContourPlusPolicy
+type ContourPlusPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ContourPlusPolicy `json:"items"`
+}
+
+// IssuerReference identifies an issuer of cert-manager.
+type IssuerReference struct {
+	// Name is the name of the issuer.
+	Name string `json:"name"`
+
+	// Kind is the kind of the issuer.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind"`
+}
+
+// ClusterContourPlusPolicySpec sets the guardrails that ContourPlusPolicies cannot exceed.
+// Unset fields put no limit on ContourPlusPolicies.
+type ClusterContourPlusPolicySpec struct {
+	// AllowedIssuers is the list of issuers that ContourPlusPolicies can set as the default issuer.
+	// +optional
+	AllowedIssuers []IssuerReference `json:"allowedIssuers,omitempty"`
+
+	// AllowedDelegatedDomains is the list of domains that ContourPlusPolicies can set as the default or allowed delegated domains.
+	// An entry may be a wildcard such as "*.acme.example.com" or a regular expression enclosed in slashes.
+	// A regular expression in ContourPlusPolicies is allowed only if the same entry is listed here.
+	// +optional
+	AllowedDelegatedDomains []string `json:"allowedDelegatedDomains,omitempty"`
+
+	// MaxCSRRevisionLimit is the maximum of CSRRevisionLimit of ContourPlusPolicies.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxCSRRevisionLimit *int32 `json:"maxCSRRevisionLimit,omitempty"`
+
+	// AllowedPropagatedAnnotations is the list of annotation keys that ContourPlusPolicies can propagate.
+	// +optional
+	AllowedPropagatedAnnotations []string `json:"allowedPropagatedAnnotations,omitempty"`
+
+	// AllowedPropagatedLabels is the list of label keys that ContourPlusPolicies can propagate.
+	// +optional
+	AllowedPropagatedLabels []string `json:"allowedPropagatedLabels,omitempty"`
+
+	// MinDNSRecordTTL is the minimum of DNSRecordTTL of ContourPlusPolicies.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinDNSRecordTTL *int32 `json:"minDNSRecordTTL,omitempty"`
+
+	// MaxDNSRecordTTL is the maximum of DNSRecordTTL of ContourPlusPolicies.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxDNSRecordTTL *int32 `json:"maxDNSRecordTTL,omitempty"`
+
+	// DomainOwners restricts the hostnames that HTTPProxies in each namespace can publish.
+	// A hostname is allowed only in the namespaces of the DomainOwner with the longest suffix matching it.
+	// Hostnames matching no suffix are not restricted.
+	// +optional
+	DomainOwners []DomainOwner `json:"domainOwners,omitempty"`
+}
+
+// DomainOwner allows the namespaces listed in Namespaces or matched by NamespaceSelector
+// to publish the hostnames under Suffix.
+type DomainOwner struct {
+	// Suffix is a domain such as "example.com", which matches itself and its subdomains.
+	// +kubebuilder:validation:MinLength=1
+	Suffix string `json:"suffix"`
+
+	// Namespaces is the list of namespaces allowed to publish the hostnames.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// NamespaceSelector selects the namespaces allowed to publish the hostnames.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="name must be default"
+
+// ClusterContourPlusPolicy sets the guardrails for ContourPlusPolicies in all namespaces.
+// Only the one named "default" is honored.
+type ClusterContourPlusPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterContourPlusPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterContourPlusPolicyList contains a list of ClusterContourPlusPolicy
+type ClusterContourPlusPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterContourPlusPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ContourPlusPolicy{}, &ContourPlusPolicyList{})
+	SchemeBuilder.Register(&ClusterContourPlusPolicy{}, &ClusterContourPlusPolicyList{})
+}
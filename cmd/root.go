@@ -35,17 +35,39 @@ func init() {
 	fs.String("metrics-addr", ":8180", "Bind address for the metrics endpoint")
 	fs.StringSlice("crds", []string{controllers.DNSEndpointKind, controllers.CertificateKind}, "List of CRD names to be created")
 	fs.String("name-prefix", "", "Prefix of CRD names to be created")
+	fs.String("owner-id", "contour-plus", "Identifier of this contour-plus instance set to the owner label of generated resources")
+	fs.Duration("orphan-sweep-interval", 0, "Interval of sweeping generated resources that the current configuration would not produce. If 0, sweeping is disabled")
+	fs.Bool("orphan-sweep-dry-run", false, "Only log orphaned resources instead of deleting them")
 	fs.String("service-name", "", "NamespacedName of the Contour LoadBalancer Service")
+	fs.StringSlice("service-names", []string{}, "List of alias=namespace/name of LoadBalancer Services selectable by HTTPProxy annotation")
+	fs.Bool("split-horizon", false, "Generate separate DNSEndpoints for the public and private addresses of the load balancer")
+	fs.String("private-service-name", "", "NamespacedName of the LoadBalancer Service whose addresses are published in the private view with split-horizon DNS")
+	fs.String("dns-view-label", controllers.DefaultDNSViewLabel, "Key of the label telling the public or private view of DNSEndpoints with split-horizon DNS")
+	fs.String("dns-target-source", controllers.DNSTargetSourceService, "Source of DNS record targets: service or httpproxy-status")
+	fs.String("ip-families", controllers.IPFamiliesDual, "IP families of the addresses published in DNS records: ipv4, ipv6 or dual")
+	fs.String("invalid-httpproxy-policy", controllers.InvalidHTTPProxyPolicyIgnore, "How to handle HTTPProxies that Contour marks invalid: ignore, hold or remove")
 	fs.String("default-issuer-name", "", "Issuer name used by default")
 	fs.String("default-issuer-kind", controllers.ClusterIssuerKind, "Issuer kind used by default")
+	fs.String("default-issuer-group", "", "Issuer group used by default. If not specified, the issuers of cert-manager are used")
+	fs.Bool("verify-issuer", false, "Create Certificates only after their issuers become ready")
+	fs.String("issuer-policy-file", "", "Path to a YAML file of the policy restricting issuers per namespace")
+	fs.StringSlice("allowed-issuer-kinds", []string{}, "List of kind.group of external issuers allowed in addition to Issuer and ClusterIssuer of cert-manager")
 	fs.String("default-delegated-domain", "", "Delegated domain used by default")
-	fs.StringSlice("allowed-delegated-domains", []string{}, "List of allowed delegated domains")
+	fs.StringSlice("allowed-delegated-domains", []string{}, "List of allowed delegated domains. Entries can be wildcards such as *.acme.example.com or regular expressions enclosed in slashes")
 	fs.Bool("allow-custom-delegations", false, "Allow custom delegated domains via annotations")
+	fs.String("delegation-target-template", "", "Go template of the targets of delegation records. If not specified, {{.ChallengeName}}.{{.Domain}} is used")
+	fs.StringArray("delegation-target-templates", []string{}, "domain=template overriding delegation-target-template for delegated domains. Can be specified multiple times")
+	fs.StringSlice("allowed-secret-namespaces", []string{}, "List of namespaces where Certificates for namespaced TLS secretNames of HTTPProxies in other namespaces can be created")
+	fs.String("secret-namespace-policy-file", "", "Path to a YAML file of the policy allowing namespaces to refer to TLS secrets in allowed-secret-namespaces")
 	fs.Uint("csr-revision-limit", 0, "Maximum number of CertificateRequest revisions to keep")
+	fs.Uint("dns-record-ttl", 3600, "TTL of DNS records used by default")
 	fs.String("ingress-class-name", "", "Ingress class name that watched by Contour Plus. If not specified, then all classes are watched")
+	fs.StringSlice("ingress-class-services", []string{}, "List of class=namespace/name mapping ingress classes to the LoadBalancer Services of their Contour")
 	fs.Bool("leader-election", true, "Enable/disable leader election")
 	fs.StringSlice("propagated-annotations", []string{}, "List of annotation keys to be propagated from HTTPProxy to generated resources")
 	fs.StringSlice("propagated-labels", []string{}, "List of label keys to be propagated from HTTPProxy to generated resources")
+	fs.Bool("hostname-arbitration", false, "Generate resources only for the oldest of the HTTPProxies using the same hostname")
+	fs.Bool("enable-policies", false, "Enable ContourPlusPolicy and ClusterContourPlusPolicy to override the defaults per namespace")
 	if err := viper.BindPFlags(fs); err != nil {
 		panic(err)
 	}
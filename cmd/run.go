@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"errors"
+	"math"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/cybozu-go/contour-plus/controllers"
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -29,6 +35,13 @@ func run() error {
 	opts := controllers.ReconcilerOptions{
 		Prefix:            viper.GetString("name-prefix"),
 		DefaultIssuerName: viper.GetString("default-issuer-name"),
+		OwnerID:           viper.GetString("owner-id"),
+	}
+	if opts.OwnerID == "" {
+		return errors.New("owner-id should not be empty")
+	}
+	if errs := validation.IsValidLabelValue(opts.OwnerID); len(errs) != 0 {
+		return errors.New("owner-id should be a valid label value: " + strings.Join(errs, ", "))
 	}
 
 	crds := viper.GetStringSlice("crds")
@@ -46,34 +59,144 @@ func run() error {
 		}
 	}
 
+	dnsTargetSource := viper.GetString("dns-target-source")
+	switch dnsTargetSource {
+	case controllers.DNSTargetSourceService, controllers.DNSTargetSourceHTTPProxyStatus:
+	default:
+		return errors.New("unsupported DNS target source: " + dnsTargetSource)
+	}
+	opts.DNSTargetSource = dnsTargetSource
+
+	opts.IPFamilies = viper.GetString("ip-families")
+	if !controllers.IsValidIPFamilies(opts.IPFamilies) {
+		return errors.New("unsupported IP families: " + opts.IPFamilies)
+	}
+
+	invalidHTTPProxyPolicy := viper.GetString("invalid-httpproxy-policy")
+	switch invalidHTTPProxyPolicy {
+	case controllers.InvalidHTTPProxyPolicyIgnore, controllers.InvalidHTTPProxyPolicyHold, controllers.InvalidHTTPProxyPolicyRemove:
+	default:
+		return errors.New("unsupported invalid HTTPProxy policy: " + invalidHTTPProxyPolicy)
+	}
+	opts.InvalidHTTPProxyPolicy = invalidHTTPProxyPolicy
+
+	serviceKeys, err := parseServiceMapping("service-names", viper.GetStringSlice("service-names"))
+	if err != nil {
+		return err
+	}
+	opts.ServiceKeys = serviceKeys
+
+	opts.IngressClassName = viper.GetString("ingress-class-name")
+	ingressClassServiceKeys, err := parseServiceMapping("ingress-class-services", viper.GetStringSlice("ingress-class-services"))
+	if err != nil {
+		return err
+	}
+	opts.IngressClassServiceKeys = ingressClassServiceKeys
+
+	// service-name can be omitted when DNS record targets are not taken from Services,
+	// or when every watched HTTPProxy is mapped to a Service by its ingress class
 	serviceName := viper.GetString("service-name")
-	nsname := strings.Split(serviceName, "/")
-	if len(nsname) != 2 || nsname[0] == "" || nsname[1] == "" {
-		return errors.New("service-name should be valid string as namespaced-name")
+	serviceNameRequired := dnsTargetSource == controllers.DNSTargetSourceService &&
+		(len(ingressClassServiceKeys) == 0 || opts.IngressClassName != "")
+	if serviceName != "" || serviceNameRequired {
+		serviceKey, err := parseNamespacedName(serviceName)
+		if err != nil {
+			return errors.New("service-name should be valid string as namespaced-name")
+		}
+		opts.ServiceKey = serviceKey
 	}
-	opts.ServiceKey = client.ObjectKey{
-		Namespace: nsname[0],
-		Name:      nsname[1],
+
+	opts.SplitHorizon = viper.GetBool("split-horizon")
+	if privateServiceName := viper.GetString("private-service-name"); privateServiceName != "" {
+		if !opts.SplitHorizon {
+			return errors.New("private-service-name requires split-horizon")
+		}
+		privateServiceKey, err := parseNamespacedName(privateServiceName)
+		if err != nil {
+			return errors.New("private-service-name should be valid string as namespaced-name")
+		}
+		opts.PrivateServiceKey = privateServiceKey
+	}
+	opts.DNSViewLabel = viper.GetString("dns-view-label")
+	if errs := validation.IsQualifiedName(opts.DNSViewLabel); len(errs) != 0 {
+		return errors.New("dns-view-label should be a valid label key: " + strings.Join(errs, ", "))
+	}
+
+	for _, entry := range viper.GetStringSlice("allowed-issuer-kinds") {
+		gk := schema.ParseGroupKind(entry)
+		if gk.Kind == "" || gk.Group == "" {
+			return errors.New("allowed-issuer-kinds should be a list of kind.group: " + entry)
+		}
+		opts.AllowedIssuerKinds = append(opts.AllowedIssuerKinds, gk)
 	}
 
 	defaultIssuerKind := viper.GetString("default-issuer-kind")
-	switch defaultIssuerKind {
-	case controllers.IssuerKind, controllers.ClusterIssuerKind:
-	default:
-		return errors.New("unsupported Issuer kind: " + defaultIssuerKind)
+	defaultIssuerGroup := viper.GetString("default-issuer-group")
+	if !controllers.IsIssuerKindAllowed(defaultIssuerKind, defaultIssuerGroup, opts.AllowedIssuerKinds) {
+		return errors.New("unsupported Issuer kind: " + schema.GroupKind{Group: defaultIssuerGroup, Kind: defaultIssuerKind}.String())
 	}
 	opts.DefaultIssuerKind = defaultIssuerKind
+	opts.DefaultIssuerGroup = defaultIssuerGroup
+	opts.VerifyIssuer = viper.GetBool("verify-issuer")
 
-	opts.IngressClassName = viper.GetString("ingress-class-name")
+	if path := viper.GetString("issuer-policy-file"); path != "" {
+		policy, err := controllers.LoadIssuerPolicy(path)
+		if err != nil {
+			return err
+		}
+		opts.IssuerPolicy = policy
+	}
 
 	opts.CSRRevisionLimit = viper.GetUint("csr-revision-limit")
 
+	opts.DefaultRecordTTL = viper.GetUint("dns-record-ttl")
+	if opts.DefaultRecordTTL == 0 || opts.DefaultRecordTTL > math.MaxInt32 {
+		return errors.New("dns-record-ttl should be between 1 and " + strconv.Itoa(math.MaxInt32))
+	}
+
+	opts.OrphanSweepInterval = viper.GetDuration("orphan-sweep-interval")
+	if opts.OrphanSweepInterval < 0 {
+		return errors.New("orphan-sweep-interval should not be negative")
+	}
+	opts.OrphanSweepDryRun = viper.GetBool("orphan-sweep-dry-run")
+
 	opts.PropagatedAnnotations = viper.GetStringSlice("propagated-annotations")
 	opts.PropagatedLabels = viper.GetStringSlice("propagated-labels")
+	opts.EnablePolicies = viper.GetBool("enable-policies")
+	opts.HostnameArbitration = viper.GetBool("hostname-arbitration")
 
 	opts.DefaultDelegatedDomain = viper.GetString("default-delegated-domain")
 	opts.AllowCustomDelegations = viper.GetBool("allow-custom-delegations")
 	opts.AllowedDelegatedDomains = viper.GetStringSlice("allowed-delegated-domains")
+	for _, pattern := range opts.AllowedDelegatedDomains {
+		if err := controllers.ValidateDomainPattern(pattern); err != nil {
+			return errors.New("allowed-delegated-domains should be a list of domains, wildcards or regular expressions: " + err.Error())
+		}
+	}
+	delegationTargets, err := parseDelegationTargetTemplates(viper.GetString("delegation-target-template"), delegationTargetTemplateEntries())
+	if err != nil {
+		return err
+	}
+	opts.DelegationTargets = delegationTargets
+
+	opts.AllowedSecretNamespaces = viper.GetStringSlice("allowed-secret-namespaces")
+	for _, ns := range opts.AllowedSecretNamespaces {
+		if errs := validation.IsDNS1123Label(ns); len(errs) != 0 {
+			return errors.New("allowed-secret-namespaces should be a list of namespace names: " + ns)
+		}
+	}
+	if path := viper.GetString("secret-namespace-policy-file"); path != "" {
+		policy, err := controllers.LoadSecretNamespacePolicy(path)
+		if err != nil {
+			return err
+		}
+		for _, ns := range policy.SecretNamespaces() {
+			if !slices.Contains(opts.AllowedSecretNamespaces, ns) {
+				return errors.New("secret namespace policy refers to a namespace not in allowed-secret-namespaces: " + ns)
+			}
+		}
+		opts.SecretNamespacePolicy = policy
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
@@ -101,3 +224,84 @@ func run() error {
 	}
 	return nil
 }
+
+// parseServiceMapping parses a list of key=namespace/name into a map of Service keys.
+func parseServiceMapping(flagName string, entries []string) (map[string]client.ObjectKey, error) {
+	keys := make(map[string]client.ObjectKey)
+	for _, entry := range entries {
+		name, serviceName, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, errors.New(flagName + " should be a list of key=namespace/name: " + entry)
+		}
+		key, err := parseNamespacedName(serviceName)
+		if err != nil {
+			return nil, errors.New(flagName + " should be a list of key=namespace/name: " + entry)
+		}
+		if _, ok := keys[name]; ok {
+			return nil, errors.New("duplicated key in " + flagName + ": " + name)
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+func parseNamespacedName(s string) (client.ObjectKey, error) {
+	nsname := strings.Split(s, "/")
+	if len(nsname) != 2 || nsname[0] == "" || nsname[1] == "" {
+		return client.ObjectKey{}, errors.New("invalid namespaced name: " + s)
+	}
+	return client.ObjectKey{
+		Namespace: nsname[0],
+		Name:      nsname[1],
+	}, nil
+}
+
+// delegationTargetTemplateEntries returns the entries of delegation-target-templates.
+// As templates may contain spaces and commas, CP_DELEGATION_TARGET_TEMPLATES gives an entry per line.
+func delegationTargetTemplateEntries() []string {
+	if value, ok := viper.Get("delegation-target-templates").(string); ok {
+		return strings.FieldsFunc(value, func(c rune) bool { return c == '\n' })
+	}
+	return viper.GetStringSlice("delegation-target-templates")
+}
+
+// parseDelegationTargetTemplates parses the default template of delegation targets and the list of domain=template.
+// It returns nil if no template is given.
+func parseDelegationTargetTemplates(defaultTemplate string, entries []string) (*controllers.DelegationTargetTemplates, error) {
+	if defaultTemplate == "" && len(entries) == 0 {
+		return nil, nil
+	}
+
+	templates := &controllers.DelegationTargetTemplates{
+		Domains: make(map[string]*template.Template),
+	}
+	if defaultTemplate != "" {
+		tmpl, err := controllers.ParseDelegationTargetTemplate(defaultTemplate)
+		if err != nil {
+			return nil, errors.New("invalid delegation-target-template: " + err.Error())
+		}
+		templates.Default = tmpl
+	}
+	for _, entry := range entries {
+		domain, text, ok := strings.Cut(entry, "=")
+		if !ok || domain == "" || text == "" {
+			return nil, errors.New("delegation-target-templates should be a list of domain=template: " + entry)
+		}
+		if err := controllers.ValidateDomainPattern(domain); err != nil {
+			return nil, errors.New("delegation-target-templates should be keyed by domains, wildcards or regular expressions: " + err.Error())
+		}
+		// domains and wildcards are matched case-insensitively without the trailing dot
+		if !strings.HasPrefix(domain, "/") {
+			domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		}
+		if _, ok := templates.Domains[domain]; ok {
+			return nil, errors.New("duplicated domain in delegation-target-templates: " + domain)
+		}
+		tmpl, err := controllers.ParseDelegationTargetTemplate(text)
+		if err != nil {
+			return nil, errors.New("invalid delegation-target-templates for " + domain + ": " + err.Error())
+		}
+		templates.Domains[domain] = tmpl
+	}
+	return templates, nil
+}
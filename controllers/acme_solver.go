@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// solverScore ranks the ACME solvers whose selectors match a hostname.
+// As cert-manager does, a match by dnsNames is preferred to one by dnsZones, a longer zone to a shorter one,
+// and more matchLabels to fewer.
+type solverScore struct {
+	dnsName bool
+	zoneLen int
+	labels  int
+}
+
+func (s solverScore) greaterThan(o solverScore) bool {
+	if s.dnsName != o.dnsName {
+		return s.dnsName
+	}
+	if s.zoneLen != o.zoneLen {
+		return s.zoneLen > o.zoneLen
+	}
+	return s.labels > o.labels
+}
+
+// matchSolver returns the score of the solver for the hostname of a Certificate with labels,
+// or false if the selector of the solver does not match.
+func matchSolver(solver map[string]interface{}, hostname string, labels map[string]string) (solverScore, bool) {
+	var score solverScore
+	selector, _, _ := unstructured.NestedMap(solver, "selector")
+
+	matchLabels, _, _ := unstructured.NestedStringMap(selector, "matchLabels")
+	for k, v := range matchLabels {
+		if value, ok := labels[k]; !ok || value != v {
+			return solverScore{}, false
+		}
+	}
+	score.labels = len(matchLabels)
+
+	dnsNames, _, _ := unstructured.NestedStringSlice(selector, "dnsNames")
+	dnsZones, _, _ := unstructured.NestedStringSlice(selector, "dnsZones")
+	if len(dnsNames) == 0 && len(dnsZones) == 0 {
+		return score, true
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+	for _, name := range dnsNames {
+		if strings.TrimSuffix(name, ".") == hostname {
+			score.dnsName = true
+			return score, true
+		}
+	}
+	// a wildcard hostname is validated in its base domain
+	domain := strings.TrimPrefix(hostname, wildcardPrefix)
+	for _, zone := range dnsZones {
+		zone = strings.TrimSuffix(zone, ".")
+		if (domain == zone || strings.HasSuffix(domain, "."+zone)) && len(zone) > score.zoneLen {
+			score.zoneLen = len(zone)
+		}
+	}
+	return score, score.zoneLen != 0
+}
+
+// usesDNS01 returns true if the ACME solver selected for the hostname of a Certificate with labels solves DNS-01 challenges.
+// The first of the solvers with the highest score is selected.
+func usesDNS01(solvers []interface{}, hostname string, labels map[string]string) bool {
+	var selected map[string]interface{}
+	var best solverScore
+	for _, s := range solvers {
+		solver, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		score, ok := matchSolver(solver, hostname, labels)
+		if !ok {
+			continue
+		}
+		if selected == nil || score.greaterThan(best) {
+			selected, best = solver, score
+		}
+	}
+	if selected == nil {
+		return false
+	}
+	_, ok := selected["dns01"]
+	return ok
+}
+
+// dns01Hostnames returns the hostnames of the HTTPProxy whose ACME challenges are solved with DNS-01
+// by the issuer of its Certificate. No hostname is returned if no Certificate is generated for the HTTPProxy,
+// e.g. when IssuerPolicy refuses the issuer.
+// External issuers and issuers that do not exist yet are assumed to solve DNS-01 challenges for all hostnames.
+func (r *HTTPProxyReconciler) dns01Hostnames(ctx context.Context, hp *projectcontourv1.HTTPProxy, hostnames []string, log logr.Logger) ([]string, error) {
+	if !r.CreateCertificate {
+		return nil, nil
+	}
+	secretKey, ok := certificateSecretKey(hp)
+	if !ok {
+		return nil, nil
+	}
+	if secretKey.Namespace != hp.Namespace {
+		allowed, err := r.isSourceNamespaceAllowed(ctx, hp.Namespace, secretKey.Namespace)
+		if err != nil || !allowed {
+			return nil, err
+		}
+		// the Certificate follows the defaults for the namespace where it is generated
+		r, err = r.forNamespace(ctx, secretKey.Namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !r.isCertificateContributor(hp) {
+		return nil, nil
+	}
+	// the issuer is decided from the first member as syncCertificate does
+	members, err := r.certificateMembers(ctx, secretKey, hp)
+	if err != nil {
+		return nil, err
+	}
+	primary := members[0]
+	_, issuer, err := r.certificateIssuer(ctx, primary)
+	if errors.Is(err, errInvalidCertificateSettings) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !issuer.isCertManager() {
+		return hostnames, nil
+	}
+
+	obj, err := r.getIssuer(ctx, issuer, secretKey.Namespace)
+	if errors.Is(err, errIssuerNotReady) {
+		log.Info("assuming DNS-01 challenges", "reason", err.Error())
+		return hostnames, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	solvers, found, _ := unstructured.NestedSlice(obj.Object, "spec", "acme", "solvers")
+	if !found {
+		return nil, nil
+	}
+
+	labels := r.objectLabels(primary)
+	var result []string
+	for _, hostname := range hostnames {
+		if usesDNS01(solvers, hostname, labels) {
+			result = append(result, hostname)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"testing"
+)
+
+func TestUsesDNS01(t *testing.T) {
+	http01 := map[string]interface{}{"ingress": map[string]interface{}{}}
+	dns01 := map[string]interface{}{"cnameStrategy": "Follow"}
+	solver := func(kind string, selector map[string]interface{}) interface{} {
+		s := map[string]interface{}{}
+		if kind == "dns01" {
+			s["dns01"] = dns01
+		} else {
+			s["http01"] = http01
+		}
+		if selector != nil {
+			s["selector"] = selector
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		solvers  []interface{}
+		hostname string
+		labels   map[string]string
+		want     bool
+	}{
+		{
+			name:     "no solver",
+			hostname: "www.example.com",
+		},
+		{
+			name:     "dns01 without selector",
+			solvers:  []interface{}{solver("dns01", nil)},
+			hostname: "www.example.com",
+			want:     true,
+		},
+		{
+			name:     "http01 without selector",
+			solvers:  []interface{}{solver("http01", nil)},
+			hostname: "www.example.com",
+		},
+		{
+			name:     "first of solvers without selector",
+			solvers:  []interface{}{solver("http01", nil), solver("dns01", nil)},
+			hostname: "www.example.com",
+		},
+		{
+			name: "dnsZones preferred to no selector",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{"dnsZones": []interface{}{"example.com"}}),
+			},
+			hostname: "www.example.com",
+			want:     true,
+		},
+		{
+			name: "dnsZones not matched",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{"dnsZones": []interface{}{"example.net"}}),
+			},
+			hostname: "www.example.com",
+		},
+		{
+			name: "longer dnsZones preferred",
+			solvers: []interface{}{
+				solver("dns01", map[string]interface{}{"dnsZones": []interface{}{"example.com"}}),
+				solver("http01", map[string]interface{}{"dnsZones": []interface{}{"www.example.com"}}),
+			},
+			hostname: "www.example.com",
+		},
+		{
+			name: "dnsNames preferred to dnsZones",
+			solvers: []interface{}{
+				solver("http01", map[string]interface{}{"dnsZones": []interface{}{"www.example.com"}}),
+				solver("dns01", map[string]interface{}{"dnsNames": []interface{}{"www.example.com"}}),
+			},
+			hostname: "www.example.com",
+			want:     true,
+		},
+		{
+			name: "wildcard hostname in dnsNames",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{"dnsNames": []interface{}{"*.example.com"}}),
+			},
+			hostname: "*.example.com",
+			want:     true,
+		},
+		{
+			name: "wildcard hostname in dnsZones of its base domain",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{"dnsZones": []interface{}{"example.com"}}),
+			},
+			hostname: "*.example.com",
+			want:     true,
+		},
+		{
+			name: "matchLabels matched",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{"matchLabels": map[string]interface{}{"solver": "dns"}}),
+			},
+			hostname: "www.example.com",
+			labels:   map[string]string{"solver": "dns", "team": "a"},
+			want:     true,
+		},
+		{
+			name: "matchLabels not matched",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{"matchLabels": map[string]interface{}{"solver": "dns"}}),
+			},
+			hostname: "www.example.com",
+			labels:   map[string]string{"solver": "http"},
+		},
+		{
+			name: "matchLabels and dnsZones both required",
+			solvers: []interface{}{
+				solver("http01", nil),
+				solver("dns01", map[string]interface{}{
+					"matchLabels": map[string]interface{}{"solver": "dns"},
+					"dnsZones":    []interface{}{"example.net"},
+				}),
+			},
+			hostname: "www.example.com",
+			labels:   map[string]string{"solver": "dns"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := usesDNS01(tc.solvers, tc.hostname, tc.labels); got != tc.want {
+				t.Errorf("usesDNS01() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
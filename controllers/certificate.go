@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// secretIndexField is the field index of HTTPProxies by the TLS secrets for which Certificates are requested
+const secretIndexField = ".contour-plus.secret"
+
+// indexSecret returns the namespaced name of the TLS secret of the HTTPProxy for secretIndexField.
+func indexSecret(obj client.Object) []string {
+	hp, ok := obj.(*projectcontourv1.HTTPProxy)
+	if !ok {
+		return nil
+	}
+	secretKey, ok := certificateSecretKey(hp)
+	if !ok {
+		return nil
+	}
+	return []string{secretKey.String()}
+}
+
+// errInvalidCertificateSettings is returned when the Certificate is left as is due to invalid settings
+var errInvalidCertificateSettings = errors.New("invalid certificate settings")
+
+// certificateSecretName returns the name of the TLS secret of the HTTPProxy,
+// or an empty string if no Certificate is requested for the HTTPProxy.
+func certificateSecretName(hp *projectcontourv1.HTTPProxy) string {
+	if hp.Annotations[testACMETLSAnnotation] != "true" {
+		return ""
+	}
+
+	vh := hp.Spec.VirtualHost
+	switch {
+	case vh == nil:
+		return ""
+	case vh.Fqdn == "":
+		return ""
+	case vh.TLS == nil:
+		return ""
+	}
+	return vh.TLS.SecretName
+}
+
+// certificateSecretKey returns the namespaced name of the TLS secret for which a Certificate is requested.
+// A secretName in the form of "namespace/name" refers to a secret delegated from another namespace
+// with TLSCertificateDelegation.
+func certificateSecretKey(hp *projectcontourv1.HTTPProxy) (client.ObjectKey, bool) {
+	secretName := certificateSecretName(hp)
+	if secretName == "" {
+		return client.ObjectKey{}, false
+	}
+	if namespace, name, ok := strings.Cut(secretName, "/"); ok {
+		if namespace == "" || name == "" {
+			return client.ObjectKey{}, false
+		}
+		return client.ObjectKey{Namespace: namespace, Name: name}, true
+	}
+	return client.ObjectKey{Namespace: hp.Namespace, Name: secretName}, true
+}
+
+// certificateConflict returns an existing Certificate that prevents the Certificate named name from being generated
+// for the secret identified by secretKey, or nil if there is none.
+// A Certificate not generated by this controller already targeting the secret is left in charge of it instead of being duplicated,
+// and a Certificate of the same name is not taken over unless it is generated by this controller for the secret
+// or owned by one of the local members.
+func (r *HTTPProxyReconciler) certificateConflict(ctx context.Context, secretKey client.ObjectKey, name string, local []*projectcontourv1.HTTPProxy) (*unstructured.Unstructured, error) {
+	list := certificateListOf()
+	if err := r.List(ctx, list, client.InNamespace(secretKey.Namespace)); err != nil {
+		return nil, err
+	}
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+		secretName, _, _ := unstructured.NestedString(obj.Object, "spec", "secretName")
+		sameName := obj.GetName() == name
+		sameSecret := secretName == secretKey.Name
+		if !sameName && !sameSecret {
+			continue
+		}
+		if !isGeneratedBy(obj, r.ownerID()) {
+			return obj, nil
+		}
+		if sameName && !sameSecret && !slices.ContainsFunc(local, func(hp *projectcontourv1.HTTPProxy) bool { return isOwnedBy(obj, hp) }) {
+			return obj, nil
+		}
+	}
+	return nil, nil
+}
+
+// isSecretNamespaceAllowed returns true if Certificates may be generated in the namespace
+// for HTTPProxies in other namespaces.
+func (r *HTTPProxyReconciler) isSecretNamespaceAllowed(namespace string) bool {
+	return slices.Contains(r.AllowedSecretNamespaces, namespace)
+}
+
+// certificateMembers returns the HTTPProxies that refer to the TLS secret identified by secretKey.
+// The members are sorted so that the HTTPProxies in the namespace of the secret come first, from the oldest.
+// hp, if not nil, is always included to avoid acting on a stale cache.
+// The other members inherit the default annotations of their Namespaces as hp does.
+func (r *HTTPProxyReconciler) certificateMembers(ctx context.Context, secretKey client.ObjectKey, hp *projectcontourv1.HTTPProxy) ([]*projectcontourv1.HTTPProxy, error) {
+	opts := []client.ListOption{client.MatchingFields{secretIndexField: secretKey.String()}}
+	if !r.isSecretNamespaceAllowed(secretKey.Namespace) {
+		opts = append(opts, client.InNamespace(secretKey.Namespace))
+	}
+	var hpList projectcontourv1.HTTPProxyList
+	if err := r.List(ctx, &hpList, opts...); err != nil {
+		return nil, err
+	}
+
+	var members []*projectcontourv1.HTTPProxy
+	if hp != nil {
+		members = append(members, hp)
+	}
+	for i := range hpList.Items {
+		other := &hpList.Items[i]
+		if hp != nil && other.UID == hp.UID {
+			continue
+		}
+		if key, ok := certificateSecretKey(other); !ok || key != secretKey {
+			continue
+		}
+		other, err := r.withNamespaceDefaults(ctx, other)
+		if err != nil {
+			return nil, err
+		}
+		if !r.isCertificateContributor(other) {
+			continue
+		}
+		allowed, err := r.isSourceNamespaceAllowed(ctx, other.Namespace, secretKey.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+		hostname, err := r.disallowedHostname(ctx, other)
+		if err != nil {
+			return nil, err
+		}
+		if hostname != "" {
+			continue
+		}
+		hostname, _, err = r.hostnameConflict(ctx, other)
+		if err != nil {
+			return nil, err
+		}
+		if hostname != "" {
+			continue
+		}
+		members = append(members, other)
+	}
+
+	slices.SortFunc(members, func(a, b *projectcontourv1.HTTPProxy) int {
+		aLocal := a.Namespace == secretKey.Namespace
+		bLocal := b.Namespace == secretKey.Namespace
+		if aLocal != bLocal {
+			if aLocal {
+				return -1
+			}
+			return 1
+		}
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return members, nil
+}
+
+// isTargetProxy returns true if resources would be generated for the HTTPProxy when reconciled,
+// regardless of its contents.
+func (r *HTTPProxyReconciler) isTargetProxy(hp *projectcontourv1.HTTPProxy) bool {
+	if hp.DeletionTimestamp != nil || hp.Annotations[excludeAnnotation] == "true" {
+		return false
+	}
+	if r.IngressClassName != "" || len(r.IngressClassServiceKeys) != 0 {
+		if !r.isClassNameMatched(hp) {
+			return false
+		}
+	}
+	if r.InvalidHTTPProxyPolicy == InvalidHTTPProxyPolicyRemove {
+		if validity, _ := validityOf(hp); validity == proxyValidityInvalid {
+			return false
+		}
+	}
+	return true
+}
+
+// isCertificateContributor returns true if the HTTPProxy would have a Certificate generated when reconciled.
+func (r *HTTPProxyReconciler) isCertificateContributor(hp *projectcontourv1.HTTPProxy) bool {
+	if !r.isTargetProxy(hp) {
+		return false
+	}
+	secretKey, ok := certificateSecretKey(hp)
+	if !ok {
+		return false
+	}
+	if secretKey.Namespace != hp.Namespace && !r.isSecretNamespaceAllowed(secretKey.Namespace) {
+		return false
+	}
+	if issuer, err := r.issuerOf(hp); err != nil || issuer.name == "" {
+		return false
+	}
+	_, err := proxyHostnames(hp)
+	return err == nil
+}
+
+// mergedHostnames returns the hostnames of all members without duplicates.
+func mergedHostnames(members []*projectcontourv1.HTTPProxy) ([]string, error) {
+	var hostnames []string
+	seen := make(map[string]bool)
+	for _, hp := range members {
+		names, err := proxyHostnames(hp)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			key := normalizeDomain(name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			hostnames = append(hostnames, name)
+		}
+	}
+	return hostnames, nil
+}
+
+// memberOwnerReferences returns the owner references to all members.
+// The first member becomes the controller.
+func memberOwnerReferences(members []*projectcontourv1.HTTPProxy) []metav1.OwnerReference {
+	gvk := projectcontourv1.GroupVersion.WithKind("HTTPProxy")
+	refs := make([]metav1.OwnerReference, 0, len(members))
+	for i, hp := range members {
+		refs = append(refs, metav1.OwnerReference{
+			APIVersion:         gvk.GroupVersion().String(),
+			Kind:               gvk.Kind,
+			Name:               hp.Name,
+			UID:                hp.UID,
+			Controller:         ptr.To(i == 0),
+			BlockOwnerDeletion: ptr.To(true),
+		})
+	}
+	return refs
+}
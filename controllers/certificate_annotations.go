@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotations of cert-manager's ingress-shim that are mapped onto the Certificate spec
+const (
+	commonNameAnnotation                 = "cert-manager.io/common-name"
+	emailSANsAnnotation                  = "cert-manager.io/email-sans"
+	uriSANsAnnotation                    = "cert-manager.io/uri-sans"
+	ipSANsAnnotation                     = "cert-manager.io/ip-sans"
+	durationAnnotation                   = "cert-manager.io/duration"
+	renewBeforeAnnotation                = "cert-manager.io/renew-before"
+	renewBeforePercentageAnnotation      = "cert-manager.io/renew-before-percentage"
+	usagesAnnotation                     = "cert-manager.io/usages"
+	privateKeyEncodingAnnotation         = "cert-manager.io/private-key-encoding"
+	privateKeyRotationPolicyAnnotation   = "cert-manager.io/private-key-rotation-policy"
+	subjectOrganizationsAnnotation       = "cert-manager.io/subject-organizations"
+	subjectOrganizationalUnitsAnnotation = "cert-manager.io/subject-organizationalunits"
+	subjectCountriesAnnotation           = "cert-manager.io/subject-countries"
+	subjectProvincesAnnotation           = "cert-manager.io/subject-provinces"
+	subjectLocalitiesAnnotation          = "cert-manager.io/subject-localities"
+	subjectPostalCodesAnnotation         = "cert-manager.io/subject-postalcodes"
+	subjectStreetAddressesAnnotation     = "cert-manager.io/subject-streetaddresses"
+	subjectSerialNumberAnnotation        = "cert-manager.io/subject-serialnumber"
+)
+
+var (
+	// keyUsages is the list of key usages accepted by cert-manager
+	keyUsages = []string{
+		"signing", "digital signature", "content commitment", "key encipherment", "key agreement",
+		"data encipherment", "cert sign", "crl sign", "encipher only", "decipher only", "any",
+		"server auth", "client auth", "code signing", "email protection", "s/mime",
+		"ipsec end system", "ipsec tunnel", "ipsec user", "timestamping", "ocsp signing",
+		"microsoft sgc", "netscape sgc",
+	}
+	privateKeyAlgorithms       = []string{"RSA", "ECDSA", "Ed25519"}
+	privateKeyEncodings        = []string{"PKCS1", "PKCS8"}
+	privateKeyRotationPolicies = []string{"Never", "Always"}
+
+	// subjectAnnotations maps the annotations to the list fields of spec.subject
+	subjectAnnotations = []struct {
+		annotation string
+		field      string
+	}{
+		{subjectOrganizationsAnnotation, "organizations"},
+		{subjectOrganizationalUnitsAnnotation, "organizationalUnits"},
+		{subjectCountriesAnnotation, "countries"},
+		{subjectProvincesAnnotation, "provinces"},
+		{subjectLocalitiesAnnotation, "localities"},
+		{subjectPostalCodesAnnotation, "postalCodes"},
+		{subjectStreetAddressesAnnotation, "streetAddresses"},
+	}
+)
+
+// applyCertificateAnnotations maps the cert-manager annotations of an HTTPProxy onto the Certificate spec
+// in the same way as ingress-shim does for an Ingress.
+// It returns an error if any of the annotations is invalid.
+func applyCertificateAnnotations(spec map[string]interface{}, annotations map[string]string) error {
+	if value, ok := annotations[commonNameAnnotation]; ok {
+		spec["commonName"] = value
+	}
+
+	if value, ok := annotations[emailSANsAnnotation]; ok {
+		spec["emailAddresses"] = splitAnnotationList(value)
+	}
+
+	if value, ok := annotations[uriSANsAnnotation]; ok {
+		uris := splitAnnotationList(value)
+		for _, uri := range uris {
+			u, err := url.Parse(uri)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", uriSANsAnnotation, uri, err)
+			}
+			// url.Parse accepts almost anything, so require an absolute URI as cert-manager does
+			if u.Scheme == "" || (u.Opaque == "" && u.Host == "") {
+				return fmt.Errorf("invalid %s %q: not an absolute URI", uriSANsAnnotation, uri)
+			}
+		}
+		spec["uris"] = uris
+	}
+
+	if value, ok := annotations[ipSANsAnnotation]; ok {
+		ips := splitAnnotationList(value)
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("invalid %s %q: not an IP address", ipSANsAnnotation, ip)
+			}
+		}
+		spec["ipAddresses"] = ips
+	}
+
+	if value, ok := annotations[durationAnnotation]; ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", durationAnnotation, value, err)
+		}
+		spec["duration"] = duration.String()
+	}
+
+	if value, ok := annotations[renewBeforeAnnotation]; ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", renewBeforeAnnotation, value, err)
+		}
+		spec["renewBefore"] = duration.String()
+	}
+
+	if value, ok := annotations[renewBeforePercentageAnnotation]; ok {
+		percentage, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", renewBeforePercentageAnnotation, value, err)
+		}
+		spec["renewBeforePercentage"] = percentage
+	}
+
+	if value, ok := annotations[usagesAnnotation]; ok {
+		usages := splitAnnotationList(value)
+		for _, usage := range usages {
+			if !slices.Contains(keyUsages, usage) {
+				return fmt.Errorf("invalid %s %q: unknown usage", usagesAnnotation, usage)
+			}
+		}
+		spec["usages"] = usages
+	}
+
+	subject := map[string]interface{}{}
+	for _, sa := range subjectAnnotations {
+		if value, ok := annotations[sa.annotation]; ok {
+			subject[sa.field] = splitAnnotationList(value)
+		}
+	}
+	if value, ok := annotations[subjectSerialNumberAnnotation]; ok {
+		subject["serialNumber"] = value
+	}
+	if len(subject) != 0 {
+		spec["subject"] = subject
+	}
+
+	privateKey := map[string]interface{}{}
+	if value, ok := annotations[privateKeyAlgorithmAnnotation]; ok {
+		if !slices.Contains(privateKeyAlgorithms, value) {
+			return fmt.Errorf("invalid %s %q: must be one of %s", privateKeyAlgorithmAnnotation, value, strings.Join(privateKeyAlgorithms, ", "))
+		}
+		privateKey["algorithm"] = value
+	}
+	if value, ok := annotations[privateKeySizeAnnotation]; ok {
+		// an invalid size has been ignored so that cert-manager uses the default size for the algorithm
+		if size, err := strconv.ParseUint(value, 10, 32); err == nil {
+			privateKey["size"] = size
+		}
+	}
+	if value, ok := annotations[privateKeyEncodingAnnotation]; ok {
+		if !slices.Contains(privateKeyEncodings, value) {
+			return fmt.Errorf("invalid %s %q: must be one of %s", privateKeyEncodingAnnotation, value, strings.Join(privateKeyEncodings, ", "))
+		}
+		privateKey["encoding"] = value
+	}
+	if value, ok := annotations[privateKeyRotationPolicyAnnotation]; ok {
+		if !slices.Contains(privateKeyRotationPolicies, value) {
+			return fmt.Errorf("invalid %s %q: must be one of %s", privateKeyRotationPolicyAnnotation, value, strings.Join(privateKeyRotationPolicies, ", "))
+		}
+		privateKey["rotationPolicy"] = value
+	}
+	if len(privateKey) != 0 {
+		spec["privateKey"] = privateKey
+	}
+	return nil
+}
+
+// splitAnnotationList splits a comma-separated annotation value as cert-manager does.
+func splitAnnotationList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
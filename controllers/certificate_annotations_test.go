@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyCertificateAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]interface{}
+		wantErr     bool
+	}{
+		{
+			name:        "No annotations",
+			annotations: map[string]string{},
+			want:        map[string]interface{}{},
+		},
+		{
+			name: "Names and lifetime",
+			annotations: map[string]string{
+				commonNameAnnotation:            "example.com",
+				emailSANsAnnotation:             "a@example.com, b@example.com",
+				uriSANsAnnotation:               "spiffe://example.com/foo,urn:example:foo",
+				ipSANsAnnotation:                "10.0.0.1,2001:db8::1",
+				durationAnnotation:              "2160h",
+				renewBeforeAnnotation:           "360h",
+				renewBeforePercentageAnnotation: "30",
+				usagesAnnotation:                "server auth,client auth",
+			},
+			want: map[string]interface{}{
+				"commonName":            "example.com",
+				"emailAddresses":        []string{"a@example.com", "b@example.com"},
+				"uris":                  []string{"spiffe://example.com/foo", "urn:example:foo"},
+				"ipAddresses":           []string{"10.0.0.1", "2001:db8::1"},
+				"duration":              "2160h0m0s",
+				"renewBefore":           "360h0m0s",
+				"renewBeforePercentage": int64(30),
+				"usages":                []string{"server auth", "client auth"},
+			},
+		},
+		{
+			name: "Subject",
+			annotations: map[string]string{
+				subjectOrganizationsAnnotation:       "Example Inc.",
+				subjectOrganizationalUnitsAnnotation: "Web,Infra",
+				subjectCountriesAnnotation:           "JP",
+				subjectSerialNumberAnnotation:        "1234",
+			},
+			want: map[string]interface{}{
+				"subject": map[string]interface{}{
+					"organizations":       []string{"Example Inc."},
+					"organizationalUnits": []string{"Web", "Infra"},
+					"countries":           []string{"JP"},
+					"serialNumber":        "1234",
+				},
+			},
+		},
+		{
+			name: "Private key",
+			annotations: map[string]string{
+				privateKeyAlgorithmAnnotation:      "ECDSA",
+				privateKeySizeAnnotation:           "384",
+				privateKeyEncodingAnnotation:       "PKCS8",
+				privateKeyRotationPolicyAnnotation: "Always",
+			},
+			want: map[string]interface{}{
+				"privateKey": map[string]interface{}{
+					"algorithm":      "ECDSA",
+					"size":           uint64(384),
+					"encoding":       "PKCS8",
+					"rotationPolicy": "Always",
+				},
+			},
+		},
+		{
+			name: "Private key rotation policy without algorithm",
+			annotations: map[string]string{
+				privateKeyRotationPolicyAnnotation: "Never",
+			},
+			want: map[string]interface{}{
+				"privateKey": map[string]interface{}{
+					"rotationPolicy": "Never",
+				},
+			},
+		},
+		{
+			name:        "Invalid duration",
+			annotations: map[string]string{durationAnnotation: "90d"},
+			wantErr:     true,
+		},
+		{
+			name:        "Invalid renew-before-percentage",
+			annotations: map[string]string{renewBeforePercentageAnnotation: "thirty"},
+			wantErr:     true,
+		},
+		{
+			name:        "Relative URI SAN",
+			annotations: map[string]string{uriSANsAnnotation: "spiffe://example.com/foo,example.com/foo"},
+			wantErr:     true,
+		},
+		{
+			name:        "URI SAN without host",
+			annotations: map[string]string{uriSANsAnnotation: "spiffe:///foo"},
+			wantErr:     true,
+		},
+		{
+			name:        "Invalid IP SAN",
+			annotations: map[string]string{ipSANsAnnotation: "10.0.0.256"},
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown usage",
+			annotations: map[string]string{usagesAnnotation: "server auth,web"},
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown private key algorithm",
+			annotations: map[string]string{privateKeyAlgorithmAnnotation: "DSA"},
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown private key encoding",
+			annotations: map[string]string{privateKeyEncodingAnnotation: "PEM"},
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown private key rotation policy",
+			annotations: map[string]string{privateKeyRotationPolicyAnnotation: "Sometimes"},
+			wantErr:     true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := map[string]interface{}{}
+			err := applyCertificateAnnotations(spec, tc.annotations)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got spec %v", spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(spec, tc.want) {
+				t.Errorf("spec = %v, want %v", spec, tc.want)
+			}
+		})
+	}
+}
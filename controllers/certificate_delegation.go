@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// syncTLSCertificateDelegation creates or updates the TLSCertificateDelegation that allows the HTTPProxies in
+// other namespaces to refer to the secret of the Certificate.
+// The TLSCertificateDelegation is controlled by the Certificate, and is deleted when there are no such HTTPProxies.
+func (r *HTTPProxyReconciler) syncTLSCertificateDelegation(ctx context.Context, cert *unstructured.Unstructured, remote []*projectcontourv1.HTTPProxy, log logr.Logger) error {
+	if len(remote) == 0 {
+		return r.deleteTLSCertificateDelegation(ctx, client.ObjectKeyFromObject(cert), log)
+	}
+
+	var namespaces []string
+	for _, hp := range remote {
+		if !slices.Contains(namespaces, hp.Namespace) {
+			namespaces = append(namespaces, hp.Namespace)
+		}
+	}
+	slices.Sort(namespaces)
+
+	secretName, _, _ := unstructured.NestedString(cert.Object, "spec", "secretName")
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(projectcontourv1.GroupVersion.WithKind(TLSCertificateDelegationKind))
+	obj.SetName(cert.GetName())
+	obj.SetNamespace(cert.GetNamespace())
+	obj.SetLabels(map[string]string{ownerLabel: r.ownerID()})
+	obj.UnstructuredContent()["spec"] = map[string]interface{}{
+		"delegations": []interface{}{
+			map[string]interface{}{
+				"secretName":       secretName,
+				"targetNamespaces": namespaces,
+			},
+		},
+	}
+
+	err := ctrl.SetControllerReference(cert, obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
+		Force:        ptr.To(true),
+		FieldManager: fieldManager,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("TLSCertificateDelegation successfully reconciled", "namespace", obj.GetNamespace(), "name", obj.GetName())
+	return nil
+}
+
+// deleteTLSCertificateDelegation deletes the TLSCertificateDelegation generated for the Certificate identified by key.
+func (r *HTTPProxyReconciler) deleteTLSCertificateDelegation(ctx context.Context, key client.ObjectKey, log logr.Logger) error {
+	if len(r.AllowedSecretNamespaces) == 0 {
+		return nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(projectcontourv1.GroupVersion.WithKind(TLSCertificateDelegationKind))
+	err := r.Get(ctx, key, obj)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isGeneratedBy(obj, r.ownerID()) {
+		return nil
+	}
+
+	uid := obj.GetUID()
+	err = r.Delete(ctx, obj, client.Preconditions{UID: &uid})
+	if k8serrors.IsNotFound(err) || k8serrors.IsConflict(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("deleted TLSCertificateDelegation no longer desired", "namespace", obj.GetNamespace(), "name", obj.GetName())
+	return nil
+}
+
+// deleteStaleCertificates deletes the Certificates generated for the secret other than the one named keepName.
+// They are left behind when the name of the Certificate changes as the HTTPProxies referring to the secret change.
+func (r *HTTPProxyReconciler) deleteStaleCertificates(ctx context.Context, secretKey client.ObjectKey, keepName string, log logr.Logger) error {
+	list := certificateListOf()
+	err := r.List(ctx, list, client.InNamespace(secretKey.Namespace), client.MatchingLabels{ownerLabel: r.ownerID()})
+	if err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+		if obj.GetName() == keepName {
+			continue
+		}
+		if secretName, _, _ := unstructured.NestedString(obj.Object, "spec", "secretName"); secretName != secretKey.Name {
+			continue
+		}
+
+		uid := obj.GetUID()
+		err := r.Delete(ctx, obj, client.Preconditions{UID: &uid})
+		if k8serrors.IsNotFound(err) || k8serrors.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Info("deleted stale Certificate", "namespace", obj.GetNamespace(), "name", obj.GetName())
+
+		if err := r.deleteTLSCertificateDelegation(ctx, client.ObjectKeyFromObject(obj), log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseCertificates updates the Certificates in other namespaces that the HTTPProxy identified by hpKey referred to,
+// so that they no longer include the HTTPProxy unless it still refers to them.
+// Such Certificates cannot be owned by the HTTPProxy, so they are tracked by the referringProxiesAnnotation.
+func (r *HTTPProxyReconciler) releaseCertificates(ctx context.Context, hpKey client.ObjectKey, log logr.Logger) error {
+	if !r.CreateCertificate || len(r.AllowedSecretNamespaces) == 0 {
+		return nil
+	}
+
+	list := certificateListOf()
+	err := r.List(ctx, list, client.MatchingLabels{ownerLabel: r.ownerID(), crossNamespaceLabel: "true"})
+	if err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		obj := &list.Items[i]
+		if !slices.Contains(parseProxyKeys(obj.GetAnnotations()[referringProxiesAnnotation]), hpKey) {
+			continue
+		}
+		secretName, _, _ := unstructured.NestedString(obj.Object, "spec", "secretName")
+		secretKey := client.ObjectKey{Namespace: obj.GetNamespace(), Name: secretName}
+		nr, err := r.forNamespace(ctx, secretKey.Namespace)
+		if err != nil {
+			return err
+		}
+		_, err = nr.syncCertificate(ctx, secretKey, nil, log)
+		if err != nil && !errors.Is(err, errInvalidCertificateSettings) && !errors.Is(err, errIssuerNotReady) {
+			return err
+		}
+	}
+	return nil
+}
+
+// certificateRequests returns the requests for the HTTPProxies that own or refer to the Certificate.
+func certificateRequests(_ context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind != "HTTPProxy" {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: obj.GetNamespace(),
+			Name:      ref.Name,
+		}})
+	}
+	for _, key := range parseProxyKeys(obj.GetAnnotations()[referringProxiesAnnotation]) {
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
+
+// joinProxyKeys returns the sorted list of the namespaced names of the HTTPProxies.
+func joinProxyKeys(hps []*projectcontourv1.HTTPProxy) string {
+	keys := make([]string, 0, len(hps))
+	for _, hp := range hps {
+		keys = append(keys, hp.Namespace+"/"+hp.Name)
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, ",")
+}
+
+func parseProxyKeys(value string) []client.ObjectKey {
+	var keys []client.ObjectKey
+	for _, item := range strings.Split(value, ",") {
+		namespace, name, ok := strings.Cut(strings.TrimSpace(item), "/")
+		if !ok || namespace == "" || name == "" {
+			continue
+		}
+		keys = append(keys, client.ObjectKey{Namespace: namespace, Name: name})
+	}
+	return keys
+}
+
+func certificateListOf() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(certManagerGroupVersion.WithKind(CertificateListKind))
+	return list
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"slices"
+	"testing"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func newProxyWithHostnames(name, fqdn, additional string) *projectcontourv1.HTTPProxy {
+	return &projectcontourv1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			UID:  types.UID(name + "-uid"),
+			Annotations: map[string]string{
+				testACMETLSAnnotation:         "true",
+				additionalHostnamesAnnotation: additional,
+			},
+		},
+		Spec: projectcontourv1.HTTPProxySpec{
+			VirtualHost: &projectcontourv1.VirtualHost{
+				Fqdn: fqdn,
+				TLS:  &projectcontourv1.TLS{SecretName: "shared"},
+			},
+		},
+	}
+}
+
+func TestMergedHostnames(t *testing.T) {
+	members := []*projectcontourv1.HTTPProxy{
+		newProxyWithHostnames("a", "a.example.com", "www.example.com"),
+		newProxyWithHostnames("b", "b.example.com", "www.example.com., a.example.com"),
+	}
+	got, err := mergedHostnames(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.example.com", "www.example.com", "b.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("mergedHostnames() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mergedHostnames() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMemberOwnerReferences(t *testing.T) {
+	members := []*projectcontourv1.HTTPProxy{
+		newProxyWithHostnames("a", "a.example.com", ""),
+		newProxyWithHostnames("b", "b.example.com", ""),
+	}
+	refs := memberOwnerReferences(members)
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 owner references, got %d", len(refs))
+	}
+	for i, ref := range refs {
+		if ref.Kind != "HTTPProxy" || ref.APIVersion != projectcontourv1.GroupVersion.String() {
+			t.Errorf("unexpected owner reference type: %s %s", ref.APIVersion, ref.Kind)
+		}
+		if ref.UID != members[i].UID {
+			t.Errorf("owner reference %d has UID %s, want %s", i, ref.UID, members[i].UID)
+		}
+		if ptr.Deref(ref.Controller, false) != (i == 0) {
+			t.Errorf("owner reference %d has controller %v", i, ptr.Deref(ref.Controller, false))
+		}
+	}
+}
+
+func TestIsCertificateContributor(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(hp *projectcontourv1.HTTPProxy)
+		want   bool
+	}{
+		{
+			name:   "Contributor",
+			modify: func(hp *projectcontourv1.HTTPProxy) {},
+			want:   true,
+		},
+		{
+			name: "Excluded",
+			modify: func(hp *projectcontourv1.HTTPProxy) {
+				hp.Annotations[excludeAnnotation] = "true"
+			},
+		},
+		{
+			name: "Without tls-acme",
+			modify: func(hp *projectcontourv1.HTTPProxy) {
+				delete(hp.Annotations, testACMETLSAnnotation)
+			},
+		},
+		{
+			name: "Another ingress class",
+			modify: func(hp *projectcontourv1.HTTPProxy) {
+				hp.Spec.IngressClassName = "other"
+			},
+		},
+		{
+			name: "Invalid additional hostname",
+			modify: func(hp *projectcontourv1.HTTPProxy) {
+				hp.Annotations[additionalHostnamesAnnotation] = "foo.*.example.com"
+			},
+		},
+	}
+	r := &HTTPProxyReconciler{
+		DefaultIssuerName: "issuer",
+		DefaultIssuerKind: ClusterIssuerKind,
+		IngressClassName:  "contour",
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hp := newProxyWithHostnames("a", "a.example.com", "")
+			hp.Spec.IngressClassName = "contour"
+			tc.modify(hp)
+			if got := r.isCertificateContributor(hp); got != tc.want {
+				t.Errorf("isCertificateContributor() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCertificateSecretKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		secretName string
+		want       types.NamespacedName
+		wantOK     bool
+	}{
+		{
+			name:       "Secret in the same namespace",
+			secretName: "tls",
+			want:       types.NamespacedName{Namespace: "app", Name: "tls"},
+			wantOK:     true,
+		},
+		{
+			name:       "Namespaced secret",
+			secretName: "certs/wildcard",
+			want:       types.NamespacedName{Namespace: "certs", Name: "wildcard"},
+			wantOK:     true,
+		},
+		{
+			name:       "Empty namespace",
+			secretName: "/wildcard",
+		},
+		{
+			name: "No secret",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hp := newProxyWithHostnames("a", "a.example.com", "")
+			hp.Namespace = "app"
+			hp.Spec.VirtualHost.TLS.SecretName = tc.secretName
+			got, ok := certificateSecretKey(hp)
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("certificateSecretKey() = %v, %v, want %v, %v", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestIndexSecret(t *testing.T) {
+	hp := newProxyWithHostnames("a", "a.example.com", "")
+	hp.Namespace = "app"
+	if got, want := indexSecret(hp), []string{"app/shared"}; !slices.Equal(got, want) {
+		t.Errorf("indexSecret() = %v, want %v", got, want)
+	}
+
+	delete(hp.Annotations, testACMETLSAnnotation)
+	if got := indexSecret(hp); got != nil {
+		t.Errorf("indexSecret() = %v, want nil without tls-acme", got)
+	}
+}
+
+func TestProxyKeys(t *testing.T) {
+	hps := []*projectcontourv1.HTTPProxy{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "foo"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "bar"}},
+	}
+	value := joinProxyKeys(hps)
+	if value != "team-a/bar,team-b/foo" {
+		t.Errorf("joinProxyKeys() = %q", value)
+	}
+
+	keys := parseProxyKeys(value + ",invalid,")
+	want := []types.NamespacedName{
+		{Namespace: "team-a", Name: "bar"},
+		{Namespace: "team-b", Name: "foo"},
+	}
+	if len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] {
+		t.Errorf("parseProxyKeys() = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// fieldManager is the field manager of server-side apply for the generated objects
+	fieldManager = "contour-plus"
+	// defaultOwnerID is the value of the owner label used when OwnerID is not configured
+	defaultOwnerID = "contour-plus"
+)
+
+// objectKey identifies an object generated in the namespace of an HTTPProxy
+type objectKey struct {
+	kind string
+	name string
+}
+
+// objectSet is a set of objects generated in the namespace of an HTTPProxy
+type objectSet map[objectKey]struct{}
+
+func (s objectSet) add(kind, name string) {
+	s[objectKey{kind: kind, name: name}] = struct{}{}
+}
+
+// addKind marks all objects of the kind to be kept as they are.
+func (s objectSet) addKind(kind string) {
+	s[objectKey{kind: kind}] = struct{}{}
+}
+
+func (s objectSet) has(kind, name string) bool {
+	if _, ok := s[objectKey{kind: kind}]; ok {
+		return true
+	}
+	_, ok := s[objectKey{kind: kind, name: name}]
+	return ok
+}
+
+// ownerID returns the value of the owner label identifying the objects generated by this controller.
+func (r *HTTPProxyReconciler) ownerID() string {
+	if r.OwnerID == "" {
+		return defaultOwnerID
+	}
+	return r.OwnerID
+}
+
+// collectGarbage deletes the objects generated for the HTTPProxy that are not in keep.
+// Only the objects owned by the HTTPProxy and generated by this controller are deleted
+// so that the objects generated by another contour-plus instance are left untouched.
+// Objects generated before the owner label was introduced are adopted; see isGeneratedBy.
+// An object shared with other HTTPProxies, such as a merged Certificate, is not deleted;
+// instead, the owner reference to the HTTPProxy is removed from it.
+func (r *HTTPProxyReconciler) collectGarbage(ctx context.Context, hp *projectcontourv1.HTTPProxy, keep objectSet, log logr.Logger) (ctrl.Result, error) {
+	var kinds []schema.GroupVersionKind
+	if r.CreateDNSEndpoint {
+		kinds = append(kinds, externalDNSGroupVersion.WithKind(DNSEndpointKind))
+	}
+	if r.CreateCertificate {
+		kinds = append(kinds, certManagerGroupVersion.WithKind(CertificateKind))
+	}
+
+	for _, gvk := range kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := r.List(ctx, list, client.InNamespace(hp.Namespace))
+		if err != nil {
+			log.Error(err, "unable to list generated resources", "kind", gvk.Kind)
+			return ctrl.Result{}, err
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !isOwnedBy(obj, hp) || !isGeneratedBy(obj, r.ownerID()) {
+				continue
+			}
+			if keep.has(gvk.Kind, obj.GetName()) {
+				continue
+			}
+
+			if hasOtherProxyOwners(obj, hp) {
+				if err := r.removeOwnerReference(ctx, obj, hp); err != nil {
+					log.Error(err, "unable to remove owner reference from shared resource", "kind", gvk.Kind, "name", obj.GetName())
+					return ctrl.Result{}, err
+				}
+				log.Info("removed owner reference from shared resource no longer desired", "kind", gvk.Kind, "name", obj.GetName())
+				continue
+			}
+
+			uid := obj.GetUID()
+			err := r.Delete(ctx, obj, client.Preconditions{UID: &uid})
+			if k8serrors.IsNotFound(err) || k8serrors.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				log.Error(err, "unable to delete generated resource", "kind", gvk.Kind, "name", obj.GetName())
+				return ctrl.Result{}, err
+			}
+			log.Info("deleted resource no longer desired", "kind", gvk.Kind, "name", obj.GetName())
+		}
+	}
+
+	if err := r.releaseCertificates(ctx, client.ObjectKeyFromObject(hp), log); err != nil {
+		log.Error(err, "unable to release Certificates in other namespaces")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// removeOwnerReference removes the owner reference to the HTTPProxy from the object.
+func (r *HTTPProxyReconciler) removeOwnerReference(ctx context.Context, obj *unstructured.Unstructured, hp *projectcontourv1.HTTPProxy) error {
+	patch := client.MergeFromWithOptions(obj.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != hp.UID {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	err := r.Patch(ctx, obj, patch)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func isOwnedBy(obj client.Object, hp *projectcontourv1.HTTPProxy) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == hp.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOtherProxyOwners returns true if the object is owned by an HTTPProxy other than hp.
+func hasOtherProxyOwners(obj client.Object, hp *projectcontourv1.HTTPProxy) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == "HTTPProxy" && ref.UID != hp.UID {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,8 @@ const (
 	CertificateListKind = "CertificateList"
 	DNSEndpointKind     = "DNSEndpoint"
 	DNSEndpointListKind = "DNSEndpointList"
+
+	TLSCertificateDelegationKind = "TLSCertificateDelegation"
 )
 
 // Constants for certificate usages
@@ -16,3 +18,23 @@ const (
 	usageKeyEncipherment  = "key encipherment"
 	usageServerAuth       = "server auth"
 )
+
+// Constants for sources of DNS record targets
+const (
+	DNSTargetSourceService         = "service"
+	DNSTargetSourceHTTPProxyStatus = "httpproxy-status"
+)
+
+// Constants for IP families of the addresses published in DNS records
+const (
+	IPFamiliesIPv4 = "ipv4"
+	IPFamiliesIPv6 = "ipv6"
+	IPFamiliesDual = "dual"
+)
+
+// Constants for policies on HTTPProxies that Contour marks invalid
+const (
+	InvalidHTTPProxyPolicyIgnore = "ignore"
+	InvalidHTTPProxyPolicyHold   = "hold"
+	InvalidHTTPProxyPolicyRemove = "remove"
+)
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	crlog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ContourPlusPolicyReconciler reports the effective policy to the status of ContourPlusPolicy
+type ContourPlusPolicyReconciler struct {
+	client.Client
+	Defaults contourplusv1alpha1.EffectivePolicy
+}
+
+// +kubebuilder:rbac:groups=contour-plus.cybozu.com,resources=contourpluspolicies/status,verbs=get;update;patch
+
+// Reconcile updates the status of ContourPlusPolicy
+func (r *ContourPlusPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := crlog.FromContext(ctx)
+
+	if req.Name != contourplusv1alpha1.PolicyName {
+		return ctrl.Result{}, nil
+	}
+	policy := &contourplusv1alpha1.ContourPlusPolicy{}
+	err := r.Get(ctx, req.NamespacedName, policy)
+	if k8serrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Error(err, "unable to get ContourPlusPolicy")
+		return ctrl.Result{}, err
+	}
+	if policy.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	guardrails, err := clusterGuardrails(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "unable to get ClusterContourPlusPolicy")
+		return ctrl.Result{}, err
+	}
+	effective, adjusted := mergePolicy(r.Defaults, &policy.Spec, guardrails)
+
+	condition := metav1.Condition{
+		Type:               contourplusv1alpha1.ContourPlusPolicyConditionAccepted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Accepted",
+		Message:            "the policy is in effect",
+		ObservedGeneration: policy.Generation,
+	}
+	if len(adjusted) != 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "GuardrailsExceeded"
+		condition.Message = "fields adjusted to the guardrails of ClusterContourPlusPolicy: " + strings.Join(adjusted, ", ")
+	}
+
+	patch := client.MergeFrom(policy.DeepCopy())
+	policy.Status.ObservedGeneration = policy.Generation
+	policy.Status.Effective = &effective
+	meta.SetStatusCondition(&policy.Status.Conditions, condition)
+	if err := r.Status().Patch(ctx, policy, patch); err != nil {
+		log.Error(err, "unable to update status of ContourPlusPolicy")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ContourPlusPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// all ContourPlusPolicies are affected by the guardrails
+	listPolicies := func(ctx context.Context, _ client.Object) []reconcile.Request {
+		var policies contourplusv1alpha1.ContourPlusPolicyList
+		if err := r.List(ctx, &policies); err != nil {
+			crlog.FromContext(ctx).Error(err, "listing ContourPlusPolicy failed")
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(policies.Items))
+		for _, p := range policies.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: p.Namespace,
+				Name:      p.Name,
+			}})
+		}
+		return requests
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&contourplusv1alpha1.ContourPlusPolicy{}).
+		Watches(&contourplusv1alpha1.ClusterContourPlusPolicy{}, handler.EnqueueRequestsFromMapFunc(listPolicies)).
+		Complete(r)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidateDomainPattern checks an entry of allowed delegated domains.
+// An entry is either a domain, a wildcard such as "*.acme.example.com" matching all of its subdomains,
+// or a regular expression enclosed in slashes such as "/acme-[a-z]+\.example\.com/" matching the whole domain.
+func ValidateDomainPattern(pattern string) error {
+	if body, ok := regexpPattern(pattern); ok {
+		if _, err := regexp.Compile(body); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		return nil
+	}
+	domain := normalizeDomain(strings.TrimPrefix(pattern, wildcardPrefix))
+	if domain == "" {
+		return errors.New("domain must not be empty")
+	}
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) != 0 {
+		return fmt.Errorf("invalid domain %q: %s", pattern, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// matchesDomainPatterns returns true if the domain matches one of the entries of allowed delegated domains.
+func matchesDomainPatterns(patterns []string, domain string) bool {
+	domain = normalizeDomain(domain)
+	if len(validation.IsDNS1123Subdomain(domain)) != 0 {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matchesDomainPattern(pattern, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDomainPattern returns true if the normalized domain matches the entry.
+// An invalid regular expression matches nothing.
+func matchesDomainPattern(pattern, domain string) bool {
+	if body, ok := regexpPattern(pattern); ok {
+		re, err := regexp.Compile(`^(?:` + body + `)$`)
+		return err == nil && re.MatchString(domain)
+	}
+	if suffix, ok := strings.CutPrefix(pattern, wildcardPrefix); ok {
+		return strings.HasSuffix(domain, "."+normalizeDomain(suffix))
+	}
+	return domain == normalizeDomain(pattern)
+}
+
+func regexpPattern(pattern string) (string, bool) {
+	if len(pattern) < 2 || !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return "", false
+	}
+	return pattern[1 : len(pattern)-1], true
+}
+
+// isDomainPatternAllowed returns true if the guardrails allow every domain matched by the entry.
+// A regular expression is allowed only if the guardrails have exactly the same entry.
+func isDomainPatternAllowed(pattern string, guardrails []string) bool {
+	if slices.Contains(guardrails, pattern) {
+		return true
+	}
+	if _, ok := regexpPattern(pattern); ok {
+		return false
+	}
+	suffix, ok := strings.CutPrefix(pattern, wildcardPrefix)
+	if !ok {
+		return matchesDomainPatterns(guardrails, pattern)
+	}
+	suffix = normalizeDomain(suffix)
+	for _, guardrail := range guardrails {
+		g, ok := strings.CutPrefix(guardrail, wildcardPrefix)
+		if !ok {
+			continue
+		}
+		g = normalizeDomain(g)
+		if suffix == g || strings.HasSuffix(suffix, "."+g) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAllowedDomains returns the entries of allowed delegated domains allowed by the guardrails,
+// and whether all entries are allowed. Empty guardrails put no limit.
+func filterAllowedDomains(patterns, guardrails []string) ([]string, bool) {
+	if len(guardrails) == 0 {
+		return patterns, true
+	}
+	filtered := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if isDomainPatternAllowed(pattern, guardrails) {
+			filtered = append(filtered, pattern)
+		}
+	}
+	return filtered, len(filtered) == len(patterns)
+}
+
+// delegatedDomainOf returns the delegated domain for the HTTPProxy.
+// A custom delegated domain given by the annotation is used if allowed.
+// Otherwise, an event is recorded on the HTTPProxy and the default delegated domain is used.
+func (r *HTTPProxyReconciler) delegatedDomainOf(hp *projectcontourv1.HTTPProxy, log logr.Logger) string {
+	domain, ok := hp.Annotations[delegatedDomainAnnotation]
+	if !ok || domain == "" || domain == r.DefaultDelegatedDomain {
+		return r.DefaultDelegatedDomain
+	}
+	if r.AllowCustomDelegations && matchesDomainPatterns(r.AllowedDelegatedDomains, domain) {
+		return domain
+	}
+
+	fallback := "no delegation record is generated"
+	if r.DefaultDelegatedDomain != "" {
+		fallback = "using the default delegated domain " + r.DefaultDelegatedDomain
+	}
+	reason := "custom delegations are disabled"
+	if r.AllowCustomDelegations {
+		reason = "it is not in the allowed delegated domains"
+	}
+	log.Info("delegated domain is not allowed", "delegatedDomain", domain, "reason", reason)
+	r.Recorder.Eventf(hp, corev1.EventTypeWarning, "DelegatedDomainNotAllowed",
+		"delegated domain %s is not allowed because %s; %s", domain, reason, fallback)
+	return r.DefaultDelegatedDomain
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestValidateDomainPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{pattern: "acme.example.com"},
+		{pattern: "*.acme.example.com"},
+		{pattern: `/acme-[a-z]+\.example\.com/`},
+		{pattern: "", wantErr: true},
+		{pattern: "*.", wantErr: true},
+		{pattern: "acme_example.com", wantErr: true},
+		{pattern: "/acme-[a-z+/", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			err := ValidateDomainPattern(tc.pattern)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateDomainPattern() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchesDomainPatterns(t *testing.T) {
+	patterns := []string{"acme.example.com", "*.tenants.example.com", `/acme-[a-z]+\.example\.net/`, "/[/"}
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{domain: "acme.example.com", want: true},
+		{domain: "ACME.example.com.", want: true},
+		{domain: "foo.acme.example.com", want: false},
+		{domain: "a.tenants.example.com", want: true},
+		{domain: "b.a.tenants.example.com", want: true},
+		{domain: "tenants.example.com", want: false},
+		{domain: "xtenants.example.com", want: false},
+		{domain: "acme-foo.example.net", want: true},
+		{domain: "acme-foo.example.net.evil.com", want: false},
+		{domain: "acme-.example.net", want: false},
+		{domain: "a b.tenants.example.com", want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.domain, func(t *testing.T) {
+			if got := matchesDomainPatterns(patterns, tc.domain); got != tc.want {
+				t.Errorf("matchesDomainPatterns() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterAllowedDomains(t *testing.T) {
+	guardrails := []string{"acme.example.com", "*.tenants.example.com", `/acme-[a-z]+\.example\.net/`}
+	patterns := []string{
+		"acme.example.com",
+		"a.tenants.example.com",
+		"*.tenants.example.com",
+		"*.a.tenants.example.com",
+		`/acme-[a-z]+\.example\.net/`,
+		"acme-foo.example.net",
+		"*.example.com",
+		`/.*/`,
+		"other.example.com",
+	}
+	want := []string{
+		"acme.example.com",
+		"a.tenants.example.com",
+		"*.tenants.example.com",
+		"*.a.tenants.example.com",
+		`/acme-[a-z]+\.example\.net/`,
+		"acme-foo.example.net",
+	}
+
+	got, ok := filterAllowedDomains(patterns, guardrails)
+	if ok {
+		t.Error("filterAllowedDomains() should report disallowed entries")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterAllowedDomains() = %v, want %v", got, want)
+	}
+
+	got, ok = filterAllowedDomains(patterns, nil)
+	if !ok || !reflect.DeepEqual(got, patterns) {
+		t.Errorf("filterAllowedDomains() without guardrails = %v, %v", got, ok)
+	}
+}
+
+func TestDelegatedDomainOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      bool
+		annotation string
+		want       string
+		wantEvent  bool
+	}{
+		{name: "No annotation", want: "acme.example.com"},
+		{name: "Allowed", allow: true, annotation: "a.tenants.example.com", want: "a.tenants.example.com"},
+		{name: "Not allowed", allow: true, annotation: "other.example.com", want: "acme.example.com", wantEvent: true},
+		{name: "Custom delegations disabled", annotation: "a.tenants.example.com", want: "acme.example.com", wantEvent: true},
+		{name: "Default domain", annotation: "acme.example.com", want: "acme.example.com"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			r := &HTTPProxyReconciler{
+				Recorder:                recorder,
+				DefaultDelegatedDomain:  "acme.example.com",
+				AllowedDelegatedDomains: []string{"*.tenants.example.com"},
+				AllowCustomDelegations:  tc.allow,
+			}
+			hp := &projectcontourv1.HTTPProxy{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+			if tc.annotation != "" {
+				hp.Annotations[delegatedDomainAnnotation] = tc.annotation
+			}
+			if got := r.delegatedDomainOf(hp, logr.Discard()); got != tc.want {
+				t.Errorf("delegatedDomainOf() = %v, want %v", got, tc.want)
+			}
+			if gotEvent := len(recorder.Events) != 0; gotEvent != tc.wantEvent {
+				t.Errorf("delegatedDomainOf() recorded event = %v, want %v", gotEvent, tc.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+)
+
+// DelegationTargetTemplates renders the targets of the CNAME records delegating DNS-01 challenges.
+// A nil DelegationTargetTemplates renders the default target, "<ChallengeName>.<Domain>".
+type DelegationTargetTemplates struct {
+	// Default is used for the delegated domains without their own templates. If nil, the default target is used.
+	Default *template.Template
+
+	// Domains maps patterns of delegated domains to their own templates.
+	// A pattern is a domain, a wildcard such as "*.example.com", or a regular expression enclosed in slashes.
+	Domains map[string]*template.Template
+}
+
+// DelegationTargetData is the data given to the templates of delegation targets.
+type DelegationTargetData struct {
+	// FQDN is the hostname without the trailing dot. A wildcard hostname is given as its base domain.
+	FQDN string
+
+	// Labels are the labels of FQDN.
+	Labels []string
+
+	// ChallengeName is the name of the record for DNS-01 challenges, "_acme-challenge.<FQDN>".
+	ChallengeName string
+
+	// Domain is the delegated domain.
+	Domain string
+
+	// Namespace and Name identify the HTTPProxy.
+	Namespace string
+	Name      string
+}
+
+// delegationTargetFuncs are the functions available in the templates of delegation targets
+var delegationTargetFuncs = template.FuncMap{
+	"replace": strings.ReplaceAll,
+	"join":    func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"lower":   strings.ToLower,
+	"sha256": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"trunc": func(n int, s string) string {
+		if n < 0 || len(s) <= n {
+			return s
+		}
+		return s[:n]
+	},
+}
+
+// ParseDelegationTargetTemplate parses the template of delegation targets.
+// The template is executed with sample data to detect references to unknown fields early.
+func ParseDelegationTargetTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("delegation-target").Funcs(delegationTargetFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := newDelegationTargetData("www.example.com", "acme.example.net", "default", "sample")
+	if _, err := renderDelegationTarget(tmpl, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func newDelegationTargetData(hostname, delegatedDomain, namespace, name string) DelegationTargetData {
+	challengeName := acmeChallengeName(hostname)
+	fqdn := strings.TrimPrefix(challengeName, acmeChallengeLabel+".")
+	return DelegationTargetData{
+		FQDN:          fqdn,
+		Labels:        strings.Split(fqdn, "."),
+		ChallengeName: challengeName,
+		Domain:        delegatedDomain,
+		Namespace:     namespace,
+		Name:          name,
+	}
+}
+
+// target returns the delegation target for hostname of the HTTPProxy.
+func (t *DelegationTargetTemplates) target(hp *projectcontourv1.HTTPProxy, hostname, delegatedDomain string) (string, error) {
+	delegatedDomain = normalizeDomain(delegatedDomain)
+	data := newDelegationTargetData(hostname, delegatedDomain, hp.Namespace, hp.Name)
+	if t == nil {
+		return data.ChallengeName + "." + data.Domain, nil
+	}
+	tmpl := t.templateFor(delegatedDomain)
+	if tmpl == nil {
+		return data.ChallengeName + "." + data.Domain, nil
+	}
+	return renderDelegationTarget(tmpl, data)
+}
+
+// templateFor returns the template for the normalized delegated domain, or Default if no pattern of Domains matches it.
+// A domain is preferred to wildcards, a longer wildcard to shorter ones, and wildcards to regular expressions,
+// which are tried in lexical order.
+func (t *DelegationTargetTemplates) templateFor(domain string) *template.Template {
+	var tmpl *template.Template
+	bestRank := -1
+	for _, pattern := range slices.Sorted(maps.Keys(t.Domains)) {
+		if !matchesDomainPattern(pattern, domain) {
+			continue
+		}
+		if rank := domainPatternRank(pattern); rank > bestRank {
+			tmpl, bestRank = t.Domains[pattern], rank
+		}
+	}
+	if tmpl == nil {
+		return t.Default
+	}
+	return tmpl
+}
+
+// domainPatternRank ranks the patterns matching the same domain.
+// A domain matched by a wildcard is longer than its suffix, so the domain itself ranks higher than any matching wildcard.
+func domainPatternRank(pattern string) int {
+	if _, ok := regexpPattern(pattern); ok {
+		return 0
+	}
+	return len(normalizeDomain(strings.TrimPrefix(pattern, wildcardPrefix))) + 1
+}
+
+// renderDelegationTarget executes the template and validates the result as a domain name.
+// Unlike hostnames, a delegation target may contain underscores as "_acme-challenge" does.
+func renderDelegationTarget(tmpl *template.Template, data DelegationTargetData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	target := strings.TrimSuffix(strings.TrimSpace(b.String()), ".")
+	if target == "" {
+		return "", fmt.Errorf("delegation target for %s must not be empty", data.FQDN)
+	}
+	if len(target) > 253 {
+		return "", fmt.Errorf("delegation target %q must be at most 253 characters", target)
+	}
+	for _, label := range strings.Split(target, ".") {
+		if label == "" || len(label) > 63 {
+			return "", fmt.Errorf("delegation target %q must consist of labels of 1 to 63 characters", target)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+				return "", fmt.Errorf("delegation target %q must consist of lowercase letters, digits, '-' and '_'", target)
+			}
+		}
+	}
+	return target, nil
+}
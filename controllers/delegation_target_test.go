@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"testing"
+	"text/template"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseDelegationTargetTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "Default shape", text: "{{.ChallengeName}}.{{.Domain}}"},
+		{name: "Functions", text: `{{.Labels | join "-"}}.{{sha256 .FQDN | trunc 32}}.{{.Domain}}`},
+		{name: "Syntax error", text: "{{.FQDN", wantErr: true},
+		{name: "Unknown field", text: "{{.Hostname}}.{{.Domain}}", wantErr: true},
+		{name: "Invalid result", text: "{{.FQDN}}..{{.Domain}}", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseDelegationTargetTemplate(tc.text)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ParseDelegationTargetTemplate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDelegationTargetTemplatesTarget(t *testing.T) {
+	mustParse := func(text string) *template.Template {
+		tmpl, err := ParseDelegationTargetTemplate(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmpl
+	}
+	templates := &DelegationTargetTemplates{
+		Default: mustParse(`{{replace .FQDN "." "-"}}.{{.Domain}}`),
+		Domains: map[string]*template.Template{
+			"acme-dns.example.net":         mustParse(`{{.Namespace}}-{{.Name}}.{{.Domain}}`),
+			"*.example.org":                mustParse(`wildcard.{{.Domain}}`),
+			"*.acme.example.org":           mustParse(`longer-wildcard.{{.Domain}}`),
+			"/acme[0-9]+\\.example\\.org/": mustParse(`regexp.{{.Domain}}`),
+			"/.*\\.example\\.org/":         mustParse(`any.{{.Domain}}`),
+			// a template rendering invalid targets is rejected by ParseDelegationTargetTemplate
+			"upper.example.net": template.Must(template.New("upper").Parse(`{{.FQDN}}.UPPER`)),
+		},
+	}
+	hp := &projectcontourv1.HTTPProxy{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"}}
+
+	tests := []struct {
+		name      string
+		templates *DelegationTargetTemplates
+		hostname  string
+		domain    string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:     "No templates",
+			hostname: "www.example.com",
+			domain:   "acme.example.net",
+			want:     "_acme-challenge.www.example.com.acme.example.net",
+		},
+		{
+			name:      "Default template",
+			templates: templates,
+			hostname:  "*.apps.example.com",
+			domain:    "acme.example.net",
+			want:      "apps-example-com.acme.example.net",
+		},
+		{
+			name:      "Template for the domain",
+			templates: templates,
+			hostname:  "www.example.com.",
+			domain:    "acme-dns.example.net",
+			want:      "team-a-web.acme-dns.example.net",
+		},
+		{
+			name:      "Template for the domain not normalized",
+			templates: templates,
+			hostname:  "www.example.com",
+			domain:    "ACME-DNS.example.net.",
+			want:      "team-a-web.acme-dns.example.net",
+		},
+		{
+			name:      "Template for a wildcard",
+			templates: templates,
+			hostname:  "www.example.com",
+			domain:    "dns.example.org",
+			want:      "wildcard.dns.example.org",
+		},
+		{
+			name:      "Longer wildcard preferred",
+			templates: templates,
+			hostname:  "www.example.com",
+			domain:    "dns.acme.example.org",
+			want:      "longer-wildcard.dns.acme.example.org",
+		},
+		{
+			name:      "Wildcard preferred to regular expression",
+			templates: templates,
+			hostname:  "www.example.com",
+			domain:    "acme1.example.org",
+			want:      "wildcard.acme1.example.org",
+		},
+		{
+			name:      "Invalid target",
+			templates: templates,
+			hostname:  "www.example.com",
+			domain:    "upper.example.net",
+			wantErr:   true,
+		},
+		{
+			name:      "No default template",
+			templates: &DelegationTargetTemplates{},
+			hostname:  "www.example.com",
+			domain:    "acme.example.net",
+			want:      "_acme-challenge.www.example.com.acme.example.net",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.templates.target(hp, tc.hostname, tc.domain)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DelegationTargetTemplates.target() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("DelegationTargetTemplates.target() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
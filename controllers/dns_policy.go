@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+)
+
+// defaultRecordTTL is the TTL of DNS records used when no TTL is configured
+const defaultRecordTTL = 3600
+
+// dnsRecordPolicy holds the properties attached to every endpoint of a DNSEndpoint
+type dnsRecordPolicy struct {
+	ttl              int64
+	providerSpecific []map[string]interface{}
+	setIdentifier    string
+	// ipFamilies is one of the IPFamilies constants, or empty for dual-stack
+	ipFamilies string
+}
+
+// dnsRecordPolicy returns the DNS record policy for the HTTPProxy.
+// The values given by annotations are validated here so that invalid ones never reach the DNSEndpoint.
+func (r *HTTPProxyReconciler) dnsRecordPolicy(hp *projectcontourv1.HTTPProxy) (dnsRecordPolicy, error) {
+	policy := dnsRecordPolicy{
+		ttl: defaultRecordTTL,
+	}
+	if r.DefaultRecordTTL > 0 {
+		policy.ttl = int64(r.DefaultRecordTTL)
+	}
+
+	if value, ok := hp.Annotations[recordTTLAnnotation]; ok {
+		ttl, err := parseRecordTTL(value)
+		if err != nil {
+			return dnsRecordPolicy{}, err
+		}
+		policy.ttl = ttl
+	}
+
+	if value, ok := hp.Annotations[providerSpecificAnnotation]; ok {
+		properties, err := parseProviderSpecific(value)
+		if err != nil {
+			return dnsRecordPolicy{}, err
+		}
+		policy.providerSpecific = properties
+	}
+
+	if value, ok := hp.Annotations[setIdentifierAnnotation]; ok {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return dnsRecordPolicy{}, fmt.Errorf("%s must not be empty", setIdentifierAnnotation)
+		}
+		policy.setIdentifier = value
+	}
+
+	policy.ipFamilies = r.IPFamilies
+	if value, ok := hp.Annotations[ipFamiliesAnnotation]; ok {
+		if !IsValidIPFamilies(value) {
+			return dnsRecordPolicy{}, fmt.Errorf("invalid %s %q: must be one of %s, %s or %s", ipFamiliesAnnotation, value, IPFamiliesIPv4, IPFamiliesIPv6, IPFamiliesDual)
+		}
+		policy.ipFamilies = value
+	}
+	return policy, nil
+}
+
+// IsValidIPFamilies returns true if value is one of the IPFamilies constants.
+func IsValidIPFamilies(value string) bool {
+	switch value {
+	case IPFamiliesIPv4, IPFamiliesIPv6, IPFamiliesDual:
+		return true
+	}
+	return false
+}
+
+// eligibleIPs returns the IP addresses of the IP families allowed by the policy.
+func (p dnsRecordPolicy) eligibleIPs(ips []net.IP) []net.IP {
+	var eligible []net.IP
+	for _, ip := range ips {
+		isIPv4 := ip.To4() != nil
+		if (p.ipFamilies == IPFamiliesIPv4 && !isIPv4) || (p.ipFamilies == IPFamiliesIPv6 && isIPv4) {
+			continue
+		}
+		eligible = append(eligible, ip)
+	}
+	return eligible
+}
+
+// hasTargets returns true if the policy leaves any target of the load balancer for the records.
+// Hostnames are only used when the load balancer has no IP address, as in makeEndpoints.
+func (p dnsRecordPolicy) hasTargets(ips []net.IP, hostnames []string) bool {
+	if len(ips) != 0 {
+		return len(p.eligibleIPs(ips)) != 0
+	}
+	return len(hostnames) != 0
+}
+
+// endpoint builds an endpoint of DNSEndpoint with the policy applied
+func (p dnsRecordPolicy) endpoint(dnsName, recordType string, targets []string) map[string]interface{} {
+	ep := map[string]interface{}{
+		"dnsName":    dnsName,
+		"targets":    targets,
+		"recordType": recordType,
+		"recordTTL":  p.ttl,
+	}
+	if len(p.providerSpecific) != 0 {
+		ep["providerSpecific"] = p.providerSpecific
+	}
+	if p.setIdentifier != "" {
+		ep["setIdentifier"] = p.setIdentifier
+	}
+	return ep
+}
+
+// parseRecordTTL parses a TTL value. As per RFC 2181, a TTL is a positive 31-bit integer.
+func parseRecordTTL(value string) (int64, error) {
+	ttl, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", recordTTLAnnotation, value, err)
+	}
+	if ttl <= 0 || ttl > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid %s %q: must be between 1 and %d", recordTTLAnnotation, value, math.MaxInt32)
+	}
+	return ttl, nil
+}
+
+// parseProviderSpecific parses a comma-separated list of name=value pairs into
+// the providerSpecific field of an endpoint.
+func parseProviderSpecific(value string) ([]map[string]interface{}, error) {
+	var properties []map[string]interface{}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid %s %q: must be in the form of name=value", providerSpecificAnnotation, item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid %s: duplicated property %q", providerSpecificAnnotation, name)
+		}
+		seen[name] = true
+		properties = append(properties, map[string]interface{}{
+			"name":  name,
+			"value": strings.TrimSpace(val),
+		})
+	}
+	if len(properties) == 0 {
+		return nil, fmt.Errorf("%s must not be empty", providerSpecificAnnotation)
+	}
+	return properties, nil
+}
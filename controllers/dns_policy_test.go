@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDNSRecordPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultTTL  uint
+		annotations map[string]string
+		want        dnsRecordPolicy
+		wantErr     bool
+	}{
+		{
+			name: "No configuration",
+			want: dnsRecordPolicy{ttl: defaultRecordTTL},
+		},
+		{
+			name:       "Default TTL",
+			defaultTTL: 300,
+			want:       dnsRecordPolicy{ttl: 300},
+		},
+		{
+			name:       "TTL annotation takes precedence",
+			defaultTTL: 300,
+			annotations: map[string]string{
+				recordTTLAnnotation: "60",
+			},
+			want: dnsRecordPolicy{ttl: 60},
+		},
+		{
+			name: "Zero TTL",
+			annotations: map[string]string{
+				recordTTLAnnotation: "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Too large TTL",
+			annotations: map[string]string{
+				recordTTLAnnotation: "2147483648",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Non-numeric TTL",
+			annotations: map[string]string{
+				recordTTLAnnotation: "1h",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Provider-specific properties and set identifier",
+			annotations: map[string]string{
+				providerSpecificAnnotation: "external-dns.alpha.kubernetes.io/cloudflare-proxied=true, aws/evaluate-target-health=false",
+				setIdentifierAnnotation:    "primary",
+			},
+			want: dnsRecordPolicy{
+				ttl: defaultRecordTTL,
+				providerSpecific: []map[string]interface{}{
+					{"name": "external-dns.alpha.kubernetes.io/cloudflare-proxied", "value": "true"},
+					{"name": "aws/evaluate-target-health", "value": "false"},
+				},
+				setIdentifier: "primary",
+			},
+		},
+		{
+			name: "Provider-specific property without value",
+			annotations: map[string]string{
+				providerSpecificAnnotation: "aws/evaluate-target-health",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicated provider-specific property",
+			annotations: map[string]string{
+				providerSpecificAnnotation: "alias=true,alias=false",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Empty provider-specific properties",
+			annotations: map[string]string{
+				providerSpecificAnnotation: " , ",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Empty set identifier",
+			annotations: map[string]string{
+				setIdentifierAnnotation: " ",
+			},
+			wantErr: true,
+		},
+		{
+			name: "IP families",
+			annotations: map[string]string{
+				ipFamiliesAnnotation: IPFamiliesIPv6,
+			},
+			want: dnsRecordPolicy{ttl: defaultRecordTTL, ipFamilies: IPFamiliesIPv6},
+		},
+		{
+			name: "Invalid IP families",
+			annotations: map[string]string{
+				ipFamiliesAnnotation: "IPv6",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &HTTPProxyReconciler{
+				DefaultRecordTTL: tc.defaultTTL,
+			}
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			got, err := r.dnsRecordPolicy(hp)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("HTTPProxyReconciler.dnsRecordPolicy() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("HTTPProxyReconciler.dnsRecordPolicy() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDNSRecordPolicyEndpoint(t *testing.T) {
+	policy := dnsRecordPolicy{
+		ttl: 60,
+		providerSpecific: []map[string]interface{}{
+			{"name": "alias", "value": "true"},
+		},
+		setIdentifier: "primary",
+	}
+	want := map[string]interface{}{
+		"dnsName":          dnsName,
+		"targets":          []string{"lb.example.com"},
+		"recordType":       "CNAME",
+		"recordTTL":        int64(60),
+		"providerSpecific": policy.providerSpecific,
+		"setIdentifier":    "primary",
+	}
+	if got := policy.endpoint(dnsName, "CNAME", []string{"lb.example.com"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("dnsRecordPolicy.endpoint() = %v, want %v", got, want)
+	}
+}
+
+func TestDNSRecordPolicyHasTargets(t *testing.T) {
+	ipv4 := []net.IP{net.ParseIP("192.0.2.1")}
+	tests := []struct {
+		name       string
+		ipFamilies string
+		ips        []net.IP
+		hostnames  []string
+		want       bool
+	}{
+		{name: "Dual-stack by default", ips: ipv4, want: true},
+		{name: "IPv4 address for IPv4", ipFamilies: IPFamiliesIPv4, ips: ipv4, want: true},
+		{name: "IPv4 address for IPv6", ipFamilies: IPFamiliesIPv6, ips: ipv4, want: false},
+		{name: "Hostnames are ignored with IP addresses", ipFamilies: IPFamiliesIPv6, ips: ipv4, hostnames: []string{"lb.example.com"}, want: false},
+		{name: "Hostnames only", ipFamilies: IPFamiliesIPv6, hostnames: []string{"lb.example.com"}, want: true},
+		{name: "No targets", want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := dnsRecordPolicy{ipFamilies: tc.ipFamilies}
+			if got := p.hasTargets(tc.ips, tc.hostnames); got != tc.want {
+				t.Errorf("dnsRecordPolicy.hasTargets() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// dnsViewAnnotation selects the DNS views where the records of an HTTPProxy are published with split-horizon DNS
+const dnsViewAnnotation = "contour-plus.cybozu.com/dns-view"
+
+// DefaultDNSViewLabel is the default key of the label telling the DNS view of DNSEndpoints
+const DefaultDNSViewLabel = "contour-plus.cybozu.com/dns-view"
+
+// Constants for DNS views
+const (
+	dnsViewPublic  = "public"
+	dnsViewPrivate = "private"
+	dnsViewBoth    = "both"
+)
+
+// privateDNSEndpointSuffix is appended to the name of the DNSEndpoint for the private view
+const privateDNSEndpointSuffix = "-private"
+
+// dnsViewOf returns the DNS view requested by the HTTPProxy.
+func dnsViewOf(hp *projectcontourv1.HTTPProxy) (string, error) {
+	value, ok := hp.Annotations[dnsViewAnnotation]
+	if !ok {
+		return dnsViewBoth, nil
+	}
+	switch value {
+	case dnsViewPublic, dnsViewPrivate, dnsViewBoth:
+		return value, nil
+	}
+	return "", fmt.Errorf("%s must be one of %s, %s or %s: %s", dnsViewAnnotation, dnsViewPublic, dnsViewPrivate, dnsViewBoth, value)
+}
+
+// splitPrivateIPs splits the IP addresses into public ones and private ones in RFC 1918 or RFC 4193 ranges.
+func splitPrivateIPs(ips []net.IP) ([]net.IP, []net.IP) {
+	var public, private []net.IP
+	for _, ip := range ips {
+		if ip.IsPrivate() {
+			private = append(private, ip)
+			continue
+		}
+		public = append(public, ip)
+	}
+	return public, private
+}
+
+// reconcileSplitHorizon creates or updates the DNSEndpoints of the HTTPProxy for the public and private views.
+// The addresses of the private view are taken from PrivateServiceKey if configured.
+// Otherwise, the addresses of the load balancer are classified by their ranges, and hostnames are public.
+// A view without addresses of the allowed IP families has no DNSEndpoint, except that the private one is kept while PrivateServiceKey has no address yet.
+func (r *HTTPProxyReconciler) reconcileSplitHorizon(ctx context.Context, hp *projectcontourv1.HTTPProxy, keep, published objectSet, hostnames []string,
+	lbIPs []net.IP, lbHostnames []string, policy dnsRecordPolicy, log logr.Logger) error {
+	name := r.Prefix + hp.Name
+	privateName := name + privateDNSEndpointSuffix
+
+	view, err := dnsViewOf(hp)
+	if err != nil {
+		log.Error(err, "invalid DNS view")
+		keep.add(DNSEndpointKind, name)
+		keep.add(DNSEndpointKind, privateName)
+		return nil
+	}
+
+	// hostnames of the load balancer are ignored when it has IP addresses
+	if len(lbIPs) != 0 {
+		lbHostnames = nil
+	}
+	publicIPs, publicHostnames := lbIPs, lbHostnames
+	var privateIPs []net.IP
+	var privateHostnames []string
+	if r.PrivateServiceKey.Name != "" {
+		var svc corev1.Service
+		if err := r.Get(ctx, r.PrivateServiceKey, &svc); err != nil {
+			return err
+		}
+		privateIPs, privateHostnames = loadBalancerTargets(svc.Status.LoadBalancer.Ingress)
+		if len(privateIPs) != 0 {
+			privateHostnames = nil
+		}
+		if len(privateIPs) == 0 && len(privateHostnames) == 0 && view != dnsViewPublic {
+			log.Info("no IP address or hostname for service " + r.PrivateServiceKey.String())
+			keep.add(DNSEndpointKind, privateName)
+		}
+	} else {
+		publicIPs, privateIPs = splitPrivateIPs(lbIPs)
+	}
+
+	for _, v := range []struct {
+		view      string
+		name      string
+		ips       []net.IP
+		hostnames []string
+	}{
+		{dnsViewPublic, name, publicIPs, publicHostnames},
+		{dnsViewPrivate, privateName, privateIPs, privateHostnames},
+	} {
+		if view != dnsViewBoth && view != v.view {
+			continue
+		}
+		if len(v.ips) == 0 && len(v.hostnames) == 0 {
+			continue
+		}
+		if !policy.hasTargets(v.ips, v.hostnames) {
+			r.noEligibleAddress(hp, v.name, policy, "load balancer for the "+v.view+" view", log)
+			continue
+		}
+		keep.add(DNSEndpointKind, v.name)
+		if err := r.applyDNSEndpoint(ctx, hp, published, v.name, v.view, hostnames, v.ips, v.hostnames, policy); err != nil {
+			return err
+		}
+		log.Info("DNSEndpoint successfully reconciled", "view", v.view)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDNSViewOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "default",
+			want: dnsViewBoth,
+		},
+		{
+			name:        "public",
+			annotations: map[string]string{dnsViewAnnotation: dnsViewPublic},
+			want:        dnsViewPublic,
+		},
+		{
+			name:        "private",
+			annotations: map[string]string{dnsViewAnnotation: dnsViewPrivate},
+			want:        dnsViewPrivate,
+		},
+		{
+			name:        "invalid",
+			annotations: map[string]string{dnsViewAnnotation: "internal"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			got, err := dnsViewOf(hp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dnsViewOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("dnsViewOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPrivateIPs(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("203.0.113.1"),
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("172.16.0.1"),
+		net.ParseIP("192.168.0.1"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("fd00::1"),
+	}
+	public, private := splitPrivateIPs(ips)
+
+	wantPublic := []net.IP{net.ParseIP("203.0.113.1"), net.ParseIP("2001:db8::1")}
+	wantPrivate := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("172.16.0.1"), net.ParseIP("192.168.0.1"), net.ParseIP("fd00::1")}
+	if !reflect.DeepEqual(public, wantPublic) {
+		t.Errorf("public = %v, want %v", public, wantPublic)
+	}
+	if !reflect.DeepEqual(private, wantPrivate) {
+		t.Errorf("private = %v, want %v", private, wantPrivate)
+	}
+}
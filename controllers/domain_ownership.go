@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// disallowedHostname returns a hostname of the HTTPProxy that its namespace may not publish
+// according to the domain owners of ClusterContourPlusPolicy, or an empty string if all hostnames are allowed.
+// Invalid hostnames are left to the validation of each generated resource.
+func (r *HTTPProxyReconciler) disallowedHostname(ctx context.Context, hp *projectcontourv1.HTTPProxy) (string, error) {
+	if !r.EnablePolicies {
+		return "", nil
+	}
+	guardrails, err := clusterGuardrails(ctx, r.Client)
+	if err != nil {
+		return "", err
+	}
+	if guardrails == nil || len(guardrails.DomainOwners) == 0 {
+		return "", nil
+	}
+	hostnames, err := proxyHostnames(hp)
+	if err != nil {
+		return "", nil
+	}
+
+	ns := &corev1.Namespace{}
+	err = r.Get(ctx, client.ObjectKey{Name: hp.Namespace}, ns)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", err
+	}
+	ns.Name = hp.Namespace
+
+	for _, hostname := range hostnames {
+		owner := domainOwnerOf(guardrails.DomainOwners, hostname)
+		if owner == nil {
+			continue
+		}
+		if !namespaceMatches(ns, owner.Namespaces, owner.NamespaceSelector) {
+			return hostname, nil
+		}
+	}
+	return "", nil
+}
+
+// domainOwnerOf returns the owner with the longest suffix matching the hostname, or nil if there is none.
+// A wildcard hostname matches the suffixes as its base domain does.
+func domainOwnerOf(owners []contourplusv1alpha1.DomainOwner, hostname string) *contourplusv1alpha1.DomainOwner {
+	name := normalizeDomain(strings.TrimPrefix(hostname, wildcardPrefix))
+
+	var found *contourplusv1alpha1.DomainOwner
+	foundLen := -1
+	for i := range owners {
+		suffix := normalizeDomain(owners[i].Suffix)
+		if name != suffix && !strings.HasSuffix(name, "."+suffix) {
+			continue
+		}
+		if len(suffix) > foundLen {
+			found = &owners[i]
+			foundLen = len(suffix)
+		}
+	}
+	return found
+}
+
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
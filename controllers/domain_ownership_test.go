@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"testing"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+)
+
+func TestDomainOwnerOf(t *testing.T) {
+	owners := []contourplusv1alpha1.DomainOwner{
+		{Suffix: "example.com", Namespaces: []string{"platform"}},
+		{Suffix: "team-a.example.com.", Namespaces: []string{"team-a"}},
+		{Suffix: "Team-B.example.com", Namespaces: []string{"team-b"}},
+	}
+
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{hostname: "example.com", want: "example.com"},
+		{hostname: "login.example.com", want: "example.com"},
+		{hostname: "team-a.example.com", want: "team-a.example.com."},
+		{hostname: "www.team-a.example.com.", want: "team-a.example.com."},
+		{hostname: "*.team-a.example.com", want: "team-a.example.com."},
+		{hostname: "app.team-b.example.com", want: "Team-B.example.com"},
+		{hostname: "notexample.com", want: ""},
+		{hostname: "example.net", want: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.hostname, func(t *testing.T) {
+			owner := domainOwnerOf(owners, tc.hostname)
+			got := ""
+			if owner != nil {
+				got = owner.Suffix
+			}
+			if got != tc.want {
+				t.Errorf("domainOwnerOf(%s) = %q, want %q", tc.hostname, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	wildcardPrefix     = "*."
+	acmeChallengeLabel = "_acme-challenge"
+
+	// maxHostnames is the maximum number of hostnames for an HTTPProxy.
+	// This is the maximum number of SANs in a certificate issued by ACME CAs such as Let's Encrypt.
+	maxHostnames = 100
+)
+
+// proxyHostnames returns the FQDN of the HTTPProxy followed by its additional hostnames.
+// It returns nil if the HTTPProxy has no FQDN.
+func proxyHostnames(hp *projectcontourv1.HTTPProxy) ([]string, error) {
+	if hp.Spec.VirtualHost == nil || hp.Spec.VirtualHost.Fqdn == "" {
+		return nil, nil
+	}
+	fqdn := hp.Spec.VirtualHost.Fqdn
+	if err := validateHostname(fqdn); err != nil {
+		return nil, err
+	}
+
+	hostnames := []string{fqdn}
+	seen := map[string]bool{
+		normalizeDomain(fqdn): true,
+	}
+	for _, hostname := range strings.Split(hp.Annotations[additionalHostnamesAnnotation], ",") {
+		hostname = strings.TrimSpace(hostname)
+		if hostname == "" {
+			continue
+		}
+		if err := validateHostname(hostname); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", additionalHostnamesAnnotation, err)
+		}
+		key := normalizeDomain(hostname)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		hostnames = append(hostnames, hostname)
+	}
+	if len(hostnames) > maxHostnames {
+		return nil, fmt.Errorf("too many hostnames: %d, must be at most %d including the FQDN", len(hostnames), maxHostnames)
+	}
+	return hostnames, nil
+}
+
+// validateHostname checks that DNS records can be published and a certificate can be issued for hostname.
+// A wildcard is only accepted as the whole left-most label, and the remaining domain must have at least two labels
+// because ACME CAs do not issue wildcard certificates directly under a TLD.
+// Hostnames are case-insensitive, so uppercase letters are accepted.
+func validateHostname(hostname string) error {
+	name := normalizeDomain(hostname)
+	if name == "" {
+		return errors.New("hostname must not be empty")
+	}
+
+	base := name
+	if strings.HasPrefix(name, wildcardPrefix) {
+		base = strings.TrimPrefix(name, wildcardPrefix)
+		if !strings.Contains(base, ".") {
+			return fmt.Errorf("wildcard hostname %q must have at least two labels after the wildcard", hostname)
+		}
+	}
+	if strings.Contains(base, "*") {
+		return fmt.Errorf("hostname %q may only contain a wildcard as the whole left-most label", hostname)
+	}
+	if errs := validation.IsDNS1123Subdomain(base); len(errs) != 0 {
+		return fmt.Errorf("invalid hostname %q: %s", hostname, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// acmeChallengeName returns the name of the record used for DNS-01 challenges for hostname.
+// The challenge for a wildcard hostname is performed on its base domain.
+func acmeChallengeName(hostname string) string {
+	name := strings.Trim(hostname, ".")
+	name = strings.TrimPrefix(name, wildcardPrefix)
+	return acmeChallengeLabel + "." + name
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// hostnameIndexField is the field index of HTTPProxies by their normalized hostnames
+const hostnameIndexField = ".contour-plus.hostnames"
+
+// indexHostnames returns the normalized hostnames of the HTTPProxy for hostnameIndexField.
+func indexHostnames(obj client.Object) []string {
+	hp, ok := obj.(*projectcontourv1.HTTPProxy)
+	if !ok {
+		return nil
+	}
+	hostnames, err := proxyHostnames(hp)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		keys = append(keys, normalizeDomain(hostname))
+	}
+	return keys
+}
+
+// hostnameConflict returns a hostname of the HTTPProxy that is also used by an older HTTPProxy, and that HTTPProxy.
+// The oldest HTTPProxy wins the hostname, and the other HTTPProxies get no resources generated.
+// An older HTTPProxy losing one of its own hostnames gets no resources either, so it does not win any hostname.
+// It returns an empty string if the HTTPProxy wins all of its hostnames.
+func (r *HTTPProxyReconciler) hostnameConflict(ctx context.Context, hp *projectcontourv1.HTTPProxy) (string, *projectcontourv1.HTTPProxy, error) {
+	if !r.HostnameArbitration {
+		return "", nil, nil
+	}
+	return r.losingHostname(ctx, hp, make(map[types.UID]bool))
+}
+
+// losingHostname returns a hostname of the HTTPProxy won by an older HTTPProxy, and that HTTPProxy.
+// winners caches the results of isHostnameWinner.
+func (r *HTTPProxyReconciler) losingHostname(ctx context.Context, hp *projectcontourv1.HTTPProxy, winners map[types.UID]bool) (string, *projectcontourv1.HTTPProxy, error) {
+	hostnames, err := proxyHostnames(hp)
+	if err != nil {
+		return "", nil, nil
+	}
+
+	for _, hostname := range hostnames {
+		var hpList projectcontourv1.HTTPProxyList
+		err := r.List(ctx, &hpList, client.MatchingFields{hostnameIndexField: normalizeDomain(hostname)})
+		if err != nil {
+			return "", nil, err
+		}
+		for i := range hpList.Items {
+			other := &hpList.Items[i]
+			if other.UID == hp.UID || !isOlderProxy(other, hp) {
+				continue
+			}
+			ok, err := r.isHostnameWinner(ctx, other, winners)
+			if err != nil {
+				return "", nil, err
+			}
+			if ok {
+				return hostname, other, nil
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// isHostnameWinner returns true if the HTTPProxy is a contender and wins all of its hostnames.
+// The recursion terminates because only older HTTPProxies are visited.
+func (r *HTTPProxyReconciler) isHostnameWinner(ctx context.Context, hp *projectcontourv1.HTTPProxy, winners map[types.UID]bool) (bool, error) {
+	if ok, found := winners[hp.UID]; found {
+		return ok, nil
+	}
+	ok, err := r.isHostnameContender(ctx, hp)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		hostname, _, err := r.losingHostname(ctx, hp, winners)
+		if err != nil {
+			return false, err
+		}
+		ok = hostname == ""
+	}
+	winners[hp.UID] = ok
+	return ok, nil
+}
+
+// isHostnameContender returns true if resources would be generated for the hostnames of the HTTPProxy
+// unless it loses hostname conflicts.
+func (r *HTTPProxyReconciler) isHostnameContender(ctx context.Context, hp *projectcontourv1.HTTPProxy) (bool, error) {
+	hp, err := r.withNamespaceDefaults(ctx, hp)
+	if err != nil {
+		return false, err
+	}
+	if !r.isTargetProxy(hp) {
+		return false, nil
+	}
+	hostname, err := r.disallowedHostname(ctx, hp)
+	if err != nil {
+		return false, err
+	}
+	return hostname == "", nil
+}
+
+// isOlderProxy returns true if a was created before b.
+// HTTPProxies created at the same time are ordered by their namespaces and names.
+func isOlderProxy(a, b *projectcontourv1.HTTPProxy) bool {
+	if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+		return c < 0
+	}
+	if c := cmp.Compare(a.Namespace, b.Namespace); c != 0 {
+		return c < 0
+	}
+	return a.Name < b.Name
+}
+
+// conflictRequests returns the requests for the other HTTPProxies sharing hostnames with the HTTPProxy
+// directly or through other HTTPProxies, so that they can win the hostnames when the HTTPProxy releases them.
+// The HTTPProxies sharing hostnames transitively are included because whether an HTTPProxy wins depends on
+// the results of older HTTPProxies.
+func (r *HTTPProxyReconciler) conflictRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	seen := map[types.NamespacedName]bool{client.ObjectKeyFromObject(obj): true}
+	hostnames := indexHostnames(obj)
+	visited := make(map[string]bool)
+	for len(hostnames) != 0 {
+		hostname := hostnames[0]
+		hostnames = hostnames[1:]
+		if visited[hostname] {
+			continue
+		}
+		visited[hostname] = true
+
+		var hpList projectcontourv1.HTTPProxyList
+		err := r.List(ctx, &hpList, client.MatchingFields{hostnameIndexField: hostname})
+		if err != nil {
+			r.Log.Error(err, "listing HTTPProxy failed")
+			return nil
+		}
+		for i := range hpList.Items {
+			hp := &hpList.Items[i]
+			key := types.NamespacedName{Namespace: hp.Namespace, Name: hp.Name}
+			if hp.UID == obj.GetUID() || seen[key] {
+				continue
+			}
+			seen[key] = true
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+			hostnames = append(hostnames, indexHostnames(hp)...)
+		}
+	}
+	return requests
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsOlderProxy(t *testing.T) {
+	now := time.Now()
+	proxy := func(namespace, name string, created time.Time) *projectcontourv1.HTTPProxy {
+		return &projectcontourv1.HTTPProxy{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		}}
+	}
+
+	tests := []struct {
+		name string
+		a, b *projectcontourv1.HTTPProxy
+		want bool
+	}{
+		{"Older", proxy("b", "b", now.Add(-time.Minute)), proxy("a", "a", now), true},
+		{"Newer", proxy("a", "a", now), proxy("b", "b", now.Add(-time.Minute)), false},
+		{"Same time, smaller namespace", proxy("a", "b", now), proxy("b", "a", now), true},
+		{"Same time, same namespace", proxy("a", "b", now), proxy("a", "a", now), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isOlderProxy(tc.a, tc.b); got != tc.want {
+				t.Errorf("isOlderProxy() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIndexHostnames(t *testing.T) {
+	hp := &projectcontourv1.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{additionalHostnamesAnnotation: "www.example.com., *.example.net"},
+		},
+		Spec: projectcontourv1.HTTPProxySpec{
+			VirtualHost: &projectcontourv1.VirtualHost{Fqdn: "example.com"},
+		},
+	}
+	want := []string{"example.com", "www.example.com", "*.example.net"}
+	if got := indexHostnames(hp); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexHostnames() = %v, want %v", got, want)
+	}
+
+	hp.Annotations[additionalHostnamesAnnotation] = "invalid_hostname"
+	if got := indexHostnames(hp); got != nil {
+		t.Errorf("indexHostnames() = %v, want nil for invalid hostnames", got)
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		wantErr  bool
+	}{
+		{name: "Hostname", hostname: "test.example.com"},
+		{name: "Fully-qualified domain name", hostname: "test.example.com."},
+		{name: "Wildcard", hostname: "*.apps.example.com"},
+		{name: "Wildcard under second-level domain", hostname: "*.example.com"},
+		{name: "Uppercase letters", hostname: "WWW.Example.com"},
+		{name: "Empty", hostname: "", wantErr: true},
+		{name: "Wildcard under TLD", hostname: "*.com", wantErr: true},
+		{name: "Wildcard only", hostname: "*", wantErr: true},
+		{name: "Wildcard in the middle", hostname: "foo.*.example.com", wantErr: true},
+		{name: "Partial wildcard label", hostname: "foo*.example.com", wantErr: true},
+		{name: "Double wildcard", hostname: "*.*.example.com", wantErr: true},
+		{name: "Invalid character", hostname: "foo_bar.example.com", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateHostname(tc.hostname)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateHostname(%q) error = %v, wantErr %v", tc.hostname, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestACMEChallengeName(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{hostname: "example.com", want: "_acme-challenge.example.com"},
+		{hostname: "example.com.", want: "_acme-challenge.example.com"},
+		{hostname: "*.apps.example.com", want: "_acme-challenge.apps.example.com"},
+	}
+	for _, tc := range tests {
+		if got := acmeChallengeName(tc.hostname); got != tc.want {
+			t.Errorf("acmeChallengeName(%q) = %v, want %v", tc.hostname, got, tc.want)
+		}
+	}
+}
+
+func TestProxyHostnames(t *testing.T) {
+	tooMany := make([]string, maxHostnames)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("host%d.example.com", i)
+	}
+
+	tests := []struct {
+		name        string
+		fqdn        string
+		annotations map[string]string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name: "No FQDN",
+		},
+		{
+			name: "FQDN only",
+			fqdn: dnsName,
+			want: []string{dnsName},
+		},
+		{
+			name: "Additional hostnames",
+			fqdn: dnsName,
+			annotations: map[string]string{
+				additionalHostnamesAnnotation: "www.example.com, legacy.example.net,,*.apps.example.com",
+			},
+			want: []string{dnsName, "www.example.com", "legacy.example.net", "*.apps.example.com"},
+		},
+		{
+			name: "Duplicated hostnames",
+			fqdn: dnsName,
+			annotations: map[string]string{
+				additionalHostnamesAnnotation: dnsName + ",www.example.com,www.example.com.,WWW.Example.com",
+			},
+			want: []string{dnsName, "www.example.com"},
+		},
+		{
+			name:    "Invalid FQDN",
+			fqdn:    "*.com",
+			wantErr: true,
+		},
+		{
+			name: "Invalid additional hostname",
+			fqdn: dnsName,
+			annotations: map[string]string{
+				additionalHostnamesAnnotation: "www.example.com,foo.*.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Too many hostnames",
+			fqdn: dnsName,
+			annotations: map[string]string{
+				additionalHostnamesAnnotation: strings.Join(tooMany, ","),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			if tc.fqdn != "" {
+				hp.Spec.VirtualHost = &projectcontourv1.VirtualHost{Fqdn: tc.fqdn}
+			}
+			got, err := proxyHostnames(hp)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("proxyHostnames() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("proxyHostnames() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
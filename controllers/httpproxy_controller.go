@@ -2,18 +2,24 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"net"
-	"slices"
 	"strconv"
 	"strings"
 
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
 	"github.com/go-logr/logr"
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,6 +39,15 @@ const (
 	ingressClassNameAnnotation        = "kubernetes.io/ingress.class"
 	contourIngressClassNameAnnotation = "projectcontour.io/ingress.class"
 	delegatedDomainAnnotation         = "contour-plus.cybozu.com/delegated-domain"
+	recordTTLAnnotation               = "contour-plus.cybozu.com/dns-record-ttl"
+	providerSpecificAnnotation        = "contour-plus.cybozu.com/dns-provider-specific"
+	setIdentifierAnnotation           = "contour-plus.cybozu.com/dns-set-identifier"
+	ipFamiliesAnnotation              = "contour-plus.cybozu.com/ip-families"
+	additionalHostnamesAnnotation     = "contour-plus.cybozu.com/additional-hostnames"
+	serviceNameAnnotation             = "contour-plus.cybozu.com/service-name"
+	referringProxiesAnnotation        = "contour-plus.cybozu.com/referring-httpproxies"
+	ownerLabel                        = "contour-plus.cybozu.com/owner"
+	crossNamespaceLabel               = "contour-plus.cybozu.com/cross-namespace"
 )
 
 // HTTPProxyReconciler reconciles a HTTPProxy object
@@ -40,28 +55,55 @@ type HTTPProxyReconciler struct {
 	client.Client
 	Log                     logr.Logger
 	Scheme                  *runtime.Scheme
+	Recorder                record.EventRecorder
 	ServiceKey              client.ObjectKey
-	IssuerKey               client.ObjectKey
+	ServiceKeys             map[string]client.ObjectKey
 	Prefix                  string
 	DefaultIssuerName       string
 	DefaultIssuerKind       string
+	DefaultIssuerGroup      string
+	AllowedIssuerKinds      []schema.GroupKind
+	VerifyIssuer            bool
+	IssuerPolicy            *IssuerPolicy
 	DefaultDelegatedDomain  string
 	AllowedDelegatedDomains []string
 	AllowCustomDelegations  bool
+	DelegationTargets       *DelegationTargetTemplates
+	AllowedSecretNamespaces []string
+	SecretNamespacePolicy   *SecretNamespacePolicy
 	CSRRevisionLimit        uint
+	DefaultRecordTTL        uint
 	CreateDNSEndpoint       bool
 	CreateCertificate       bool
 	IngressClassName        string
+	IngressClassServiceKeys map[string]client.ObjectKey
+	DNSTargetSource         string
+	InvalidHTTPProxyPolicy  string
+	OwnerID                 string
 	PropagatedAnnotations   []string
 	PropagatedLabels        []string
+	EnablePolicies          bool
+	HostnameArbitration     bool
+	SplitHorizon            bool
+	PrivateServiceKey       client.ObjectKey
+	DNSViewLabel            string
+	IPFamilies              string
+
+	// base is the reconciler whose defaults are overridden by ContourPlusPolicy; see forNamespace
+	base *HTTPProxyReconciler
 }
 
 // +kubebuilder:rbac:groups=projectcontour.io,resources=httpproxies,verbs=get;list;watch
 // +kubebuilder:rbac:groups=projectcontour.io,resources=httpproxies/status,verbs=get
+// +kubebuilder:rbac:groups=projectcontour.io,resources=tlscertificatedelegations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cert-manager.io,resources=issuers;clusterissuers,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=services/status,verbs=get
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=contour-plus.cybozu.com,resources=contourpluspolicies;clustercontourpluspolicies,verbs=get;list;watch
 
 // Reconcile creates/updates CRDs from given HTTPProxy
 func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -73,12 +115,27 @@ func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		Namespace: req.Namespace,
 		Name:      req.Name,
 	}
+
+	// losing is true while the HTTPProxy loses a hostname conflict.
+	// undecided keeps the metric as it is when the reconciliation fails before hostnames are arbitrated.
+	losing, undecided := false, false
+	defer func() {
+		if !undecided {
+			losingProxies.set(objKey, losing)
+		}
+	}()
+
 	err := r.Get(ctx, objKey, hp)
 	if k8serrors.IsNotFound(err) {
+		if err := r.releaseCertificates(ctx, objKey, log); err != nil {
+			log.Error(err, "unable to release Certificates in other namespaces")
+			return ctrl.Result{}, err
+		}
 		return ctrl.Result{}, nil
 	}
 	if err != nil {
 		log.Error(err, "unable to get HTTPProxy resources")
+		undecided = true
 		return ctrl.Result{}, err
 	}
 
@@ -86,152 +143,453 @@ func (r *HTTPProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
+	hp, err = r.withNamespaceDefaults(ctx, hp)
+	if err != nil {
+		log.Error(err, "unable to get Namespace")
+		undecided = true
+		return ctrl.Result{}, err
+	}
+
 	if hp.Annotations[excludeAnnotation] == "true" {
-		return ctrl.Result{}, nil
+		return r.collectGarbage(ctx, hp, nil, log)
 	}
 
-	if r.IngressClassName != "" {
+	// the resources generated for an HTTPProxy moved to another class are deleted;
+	// those generated by the instance serving the class have its owner ID and are left untouched
+	if r.IngressClassName != "" || len(r.IngressClassServiceKeys) != 0 {
 		if !r.isClassNameMatched(hp) {
+			return r.collectGarbage(ctx, hp, nil, log)
+		}
+	}
+
+	switch r.InvalidHTTPProxyPolicy {
+	case InvalidHTTPProxyPolicyHold, InvalidHTTPProxyPolicyRemove:
+		validity, reason := validityOf(hp)
+		switch {
+		case validity == proxyValidityUnknown:
+			// Contour will update the status soon, and the update triggers another reconciliation
+			log.Info("HTTPProxy is not validated by Contour yet", "reason", reason)
+			return ctrl.Result{}, nil
+		case validity == proxyValidityInvalid && r.InvalidHTTPProxyPolicy == InvalidHTTPProxyPolicyRemove:
+			log.Info("HTTPProxy is invalid; removing generated resources", "reason", reason)
+			return r.collectGarbage(ctx, hp, nil, log)
+		case validity == proxyValidityInvalid:
+			log.Info("HTTPProxy is invalid; holding generated resources", "reason", reason)
 			return ctrl.Result{}, nil
 		}
 	}
 
-	if err := r.reconcileDNSEndpoint(ctx, hp, log); err != nil {
+	hostname, err := r.disallowedHostname(ctx, hp)
+	if err != nil {
+		log.Error(err, "unable to check domain owners")
+		undecided = true
+		return ctrl.Result{}, err
+	}
+	if hostname != "" {
+		log.Info("hostname is not allowed in the namespace", "hostname", hostname)
+		r.Recorder.Eventf(hp, corev1.EventTypeWarning, "DomainNotAllowed",
+			"hostname %s is not allowed in namespace %s by ClusterContourPlusPolicy; no DNSEndpoint or Certificate is generated", hostname, hp.Namespace)
+		return r.collectGarbage(ctx, hp, nil, log)
+	}
+
+	hostname, winner, err := r.hostnameConflict(ctx, hp)
+	if err != nil {
+		log.Error(err, "unable to check hostname conflicts")
+		undecided = true
+		return ctrl.Result{}, err
+	}
+	if hostname != "" {
+		log.Info("hostname is used by an older HTTPProxy", "hostname", hostname, "winner", client.ObjectKeyFromObject(winner))
+		losing = true
+		r.Recorder.Eventf(hp, corev1.EventTypeWarning, "HostnameConflict",
+			"hostname %s is used by HTTPProxy %s/%s created earlier; no DNSEndpoint or Certificate is generated", hostname, winner.Namespace, winner.Name)
+		return r.collectGarbage(ctx, hp, nil, log)
+	}
+
+	// nr has the defaults for the namespace of the HTTPProxy
+	nr, err := r.forNamespace(ctx, hp.Namespace)
+	if err != nil {
+		log.Error(err, "unable to get ContourPlusPolicy")
+		return ctrl.Result{}, err
+	}
+
+	// keep collects the objects that should remain for the HTTPProxy.
+	// Objects that cannot be updated due to transient or configuration errors are kept as they are.
+	keep := make(objectSet)
+	// published collects the DNSEndpoints applied in this reconciliation.
+	published := make(objectSet)
+
+	if err := nr.reconcileDNSEndpoint(ctx, hp, keep, published, log); err != nil {
 		log.Error(err, "unable to reconcile DNSEndpoint")
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileDelegationDNSEndpoint(ctx, hp, log); err != nil {
+	if err := nr.reconcileDelegationDNSEndpoint(ctx, hp, keep, published, log); err != nil {
 		log.Error(err, "unable to reconcile delegation DNSEndpoint")
 		return ctrl.Result{}, err
 	}
 
-	if err := r.reconcileCertificate(ctx, hp, log); err != nil {
+	// issuerErr holds off the Certificate until its issuer gets ready.
+	// It is returned after collecting garbage so that the HTTPProxy is reconciled again with backoff.
+	var issuerErr error
+	if err := nr.reconcileCertificate(ctx, hp, keep, log); errors.Is(err, errIssuerNotReady) {
+		log.Info("waiting for issuer", "reason", err.Error())
+		issuerErr = err
+	} else if err != nil {
 		log.Error(err, "unable to reconcile Certificate")
 		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+
+	result, err := r.collectGarbage(ctx, hp, keep, log)
+	if err != nil {
+		return result, err
+	}
+	return result, issuerErr
 }
 
+// isClassNameMatched returns true if the HTTPProxy belongs to IngressClassName or
+// to one of the ingress classes of IngressClassServiceKeys.
 func (r *HTTPProxyReconciler) isClassNameMatched(hp *projectcontourv1.HTTPProxy) bool {
-	ingressClassName := hp.Annotations[ingressClassNameAnnotation]
-	if ingressClassName != "" {
-		if ingressClassName != r.IngressClassName {
-			return false
-		}
+	className, ok := proxyIngressClassName(hp)
+	if !ok {
+		return false
 	}
-
-	contourIngressClassName := hp.Annotations[contourIngressClassNameAnnotation]
-	if contourIngressClassName != "" {
-		if contourIngressClassName != r.IngressClassName {
-			return false
-		}
+	if r.IngressClassName != "" && className == r.IngressClassName {
+		return true
 	}
+	_, ok = r.IngressClassServiceKeys[className]
+	return ok
+}
 
-	specIngressClassName := hp.Spec.IngressClassName
-	if specIngressClassName != "" {
-		if specIngressClassName != r.IngressClassName {
-			return false
+// proxyIngressClassName returns the ingress class name of the HTTPProxy.
+// The class name can be given by the annotations and the spec field, and all of the given values must be the same.
+// It returns false if no class name is given or the given values are inconsistent.
+func proxyIngressClassName(hp *projectcontourv1.HTTPProxy) (string, bool) {
+	var className string
+	for _, name := range []string{
+		hp.Annotations[ingressClassNameAnnotation],
+		hp.Annotations[contourIngressClassNameAnnotation],
+		hp.Spec.IngressClassName,
+	} {
+		if name == "" {
+			continue
 		}
+		if className != "" && className != name {
+			return "", false
+		}
+		className = name
 	}
-
-	if contourIngressClassName == "" && ingressClassName == "" && specIngressClassName == "" {
-		return false
-	}
-
-	return true
+	return className, className != ""
 }
 
-func (r *HTTPProxyReconciler) reconcileDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+func (r *HTTPProxyReconciler) reconcileDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, keep, published objectSet, log logr.Logger) error {
 	if !r.CreateDNSEndpoint {
 		return nil
 	}
+	name := r.Prefix + hp.Name
 
-	if hp.Spec.VirtualHost == nil {
+	hostnames, err := proxyHostnames(hp)
+	if err != nil {
+		log.Error(err, "invalid hostnames")
+		r.keepDNSEndpoints(keep, name)
 		return nil
 	}
-	fqdn := hp.Spec.VirtualHost.Fqdn
-	if len(fqdn) == 0 {
+	if len(hostnames) == 0 {
 		return nil
 	}
 
-	// Get IP list of loadbalancer Service
-	var serviceIPs []net.IP
-	var svc corev1.Service
-	err := r.Get(ctx, r.ServiceKey, &svc)
+	// Get IP address and hostname list of loadbalancer
+	var ingresses []corev1.LoadBalancerIngress
+	var source string
+	switch r.DNSTargetSource {
+	case DNSTargetSourceHTTPProxyStatus:
+		// Contour publishes the address of Envoy to the status of valid HTTPProxies
+		ingresses = hp.Status.LoadBalancer.Ingress
+		source = "HTTPProxy status"
+	default:
+		serviceKey, err := r.serviceKeyFor(hp)
+		if err != nil {
+			log.Error(err, "invalid service")
+			r.keepDNSEndpoints(keep, name)
+			return nil
+		}
+		var svc corev1.Service
+		err = r.Get(ctx, serviceKey, &svc)
+		if err != nil {
+			return err
+		}
+		ingresses = svc.Status.LoadBalancer.Ingress
+		source = "service " + serviceKey.String()
+	}
+
+	lbIPs, lbHostnames := loadBalancerTargets(ingresses)
+	if len(lbIPs) == 0 && len(lbHostnames) == 0 {
+		log.Info("no IP address or hostname for " + source)
+		// we can return nil here because the controller will be notified
+		// as soon as a new IP address is assigned to the service or the HTTPProxy.
+		r.keepDNSEndpoints(keep, name)
+		return nil
+	}
+	if len(lbIPs) != 0 && len(lbHostnames) != 0 {
+		log.Info("load balancer has both IP addresses and hostnames; hostnames are ignored", "source", source)
+	}
+
+	policy, err := r.dnsRecordPolicy(hp)
 	if err != nil {
-		return err
+		log.Error(err, "invalid DNS record policy")
+		r.keepDNSEndpoints(keep, name)
+		return nil
 	}
 
-	for _, ing := range svc.Status.LoadBalancer.Ingress {
-		if len(ing.IP) == 0 {
-			continue
-		}
-		serviceIPs = append(serviceIPs, net.ParseIP(ing.IP))
+	if r.SplitHorizon {
+		return r.reconcileSplitHorizon(ctx, hp, keep, published, hostnames, lbIPs, lbHostnames, policy, log)
 	}
-	if len(serviceIPs) == 0 {
-		log.Info("no IP address for service " + r.ServiceKey.String())
-		// we can return nil here because the controller will be notified
-		// as soon as a new IP address is assigned to the service.
+
+	if !policy.hasTargets(lbIPs, lbHostnames) {
+		r.noEligibleAddress(hp, name, policy, source, log)
 		return nil
 	}
+	keep.add(DNSEndpointKind, name)
+	if err := r.applyDNSEndpoint(ctx, hp, published, name, "", hostnames, lbIPs, lbHostnames, policy); err != nil {
+		return err
+	}
+
+	log.Info("DNSEndpoint successfully reconciled")
+	return nil
+}
+
+// noEligibleAddress records an event on the HTTPProxy whose DNSEndpoint is not generated
+// because the load balancer has no address of the IP families allowed by the policy.
+func (r *HTTPProxyReconciler) noEligibleAddress(hp *projectcontourv1.HTTPProxy, name string, policy dnsRecordPolicy, source string, log logr.Logger) {
+	log.Info("no address of the allowed IP families", "ipFamilies", policy.ipFamilies, "source", source)
+	r.Recorder.Eventf(hp, corev1.EventTypeWarning, "NoEligibleAddress",
+		"%s has no address of IP families %s; DNSEndpoint %s is not generated", source, policy.ipFamilies, name)
+}
+
+// keepDNSEndpoints keeps the DNSEndpoints pointing the hostnames of an HTTPProxy at the load balancer as they are.
+func (r *HTTPProxyReconciler) keepDNSEndpoints(keep objectSet, name string) {
+	keep.add(DNSEndpointKind, name)
+	if r.SplitHorizon {
+		keep.add(DNSEndpointKind, name+privateDNSEndpointSuffix)
+	}
+}
+
+// applyDNSEndpoint creates or updates the DNSEndpoint pointing the hostnames at the load balancer,
+// and adds it to published.
+// view, if not empty, is set to the DNSViewLabel of the DNSEndpoint.
+func (r *HTTPProxyReconciler) applyDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, published objectSet, name, view string, hostnames []string,
+	lbIPs []net.IP, lbHostnames []string, policy dnsRecordPolicy) error {
+	if conflict, err := r.dnsEndpointConflict(ctx, hp, name); err != nil || conflict {
+		return err
+	}
 
 	obj := &unstructured.Unstructured{}
 	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
-	obj.SetName(r.Prefix + hp.Name)
+	obj.SetName(name)
 	obj.SetNamespace(hp.Namespace)
 	obj.SetAnnotations(r.generateObjectAnnotations(hp))
-	obj.SetLabels(r.generateObjectLabels(hp))
+	obj.SetLabels(r.dnsEndpointLabels(hp, view))
+	var endpoints []map[string]interface{}
+	for _, hostname := range hostnames {
+		endpoints = append(endpoints, makeEndpoints(hostname, lbIPs, lbHostnames, policy)...)
+	}
 	obj.UnstructuredContent()["spec"] = map[string]interface{}{
-		"endpoints": makeEndpoints(fqdn, serviceIPs),
+		"endpoints": endpoints,
 	}
-	err = ctrl.SetControllerReference(hp, obj, r.Scheme)
+	err := ctrl.SetControllerReference(hp, obj, r.Scheme)
 	if err != nil {
 		return err
 	}
-	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
+	if err := r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
 		Force:        ptr.To(true),
-		FieldManager: "contour-plus",
-	})
-	if err != nil {
+		FieldManager: fieldManager,
+	}); err != nil {
 		return err
 	}
-
-	log.Info("DNSEndpoint successfully reconciled")
+	published.add(DNSEndpointKind, name)
 	return nil
 }
 
-func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
-	if !r.CreateDNSEndpoint {
-		return nil
+// hasPrimaryDNSEndpoint returns true if a DNSEndpoint for the hostnames of the HTTPProxy is applied in this reconciliation,
+// or is kept as it is and exists.
+func (r *HTTPProxyReconciler) hasPrimaryDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, keep, published objectSet) (bool, error) {
+	primary := r.Prefix + hp.Name
+	for _, name := range []string{primary, primary + privateDNSEndpointSuffix} {
+		if published.has(DNSEndpointKind, name) {
+			return true, nil
+		}
+		if !keep.has(DNSEndpointKind, name) {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+		err := r.Get(ctx, client.ObjectKey{Namespace: hp.Namespace, Name: name}, obj)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if isOwnedBy(obj, hp) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dnsEndpointConflict returns true if the DNSEndpoint is controlled by another object,
+// e.g. the primary DNSEndpoint of an HTTPProxy whose name is that of the private DNSEndpoint of hp,
+// and records an event on the HTTPProxy.
+func (r *HTTPProxyReconciler) dnsEndpointConflict(ctx context.Context, hp *projectcontourv1.HTTPProxy, name string) (bool, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
+	err := r.Get(ctx, client.ObjectKey{Namespace: hp.Namespace, Name: name}, obj)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.UID == hp.UID {
+		return false, nil
+	}
+	r.Recorder.Eventf(hp, corev1.EventTypeWarning, "DNSEndpointConflict",
+		"DNSEndpoint %s is controlled by %s %s; it is not updated", name, owner.Kind, owner.Name)
+	return true, nil
+}
+
+// dnsEndpointLabels returns the labels of a DNSEndpoint generated for the HTTPProxy in the DNS view.
+func (r *HTTPProxyReconciler) dnsEndpointLabels(hp *projectcontourv1.HTTPProxy, view string) map[string]string {
+	labels := r.objectLabels(hp)
+	if view != "" {
+		labels[r.dnsViewLabel()] = view
+	}
+	return labels
+}
+
+func (r *HTTPProxyReconciler) dnsViewLabel() string {
+	if r.DNSViewLabel == "" {
+		return DefaultDNSViewLabel
+	}
+	return r.DNSViewLabel
+}
+
+// serviceKeyFor returns the key of the LoadBalancer Service that the HTTPProxy's DNS records point at.
+// An HTTPProxy can select one of ServiceKeys by its alias with the annotation.
+// Otherwise, the Service mapped from the ingress class of the HTTPProxy, or ServiceKey is used.
+func (r *HTTPProxyReconciler) serviceKeyFor(hp *projectcontourv1.HTTPProxy) (client.ObjectKey, error) {
+	if alias, ok := hp.Annotations[serviceNameAnnotation]; ok {
+		key, ok := r.ServiceKeys[alias]
+		if !ok {
+			return client.ObjectKey{}, fmt.Errorf("service %q is not allowed", alias)
+		}
+		return key, nil
+	}
+
+	if className, ok := proxyIngressClassName(hp); ok {
+		if key, ok := r.IngressClassServiceKeys[className]; ok {
+			return key, nil
+		}
+	}
+
+	if r.ServiceKey.Name == "" {
+		return client.ObjectKey{}, errors.New("no service for the HTTPProxy")
+	}
+	return r.ServiceKey, nil
+}
+
+// isLoadBalancerService returns true if DNS records may point at the Service specified by key.
+func (r *HTTPProxyReconciler) isLoadBalancerService(key client.ObjectKey) bool {
+	if key == r.ServiceKey {
+		return true
+	}
+	for _, k := range r.ServiceKeys {
+		if k == key {
+			return true
+		}
 	}
+	for _, k := range r.IngressClassServiceKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
 
-	delegatedDomain := r.DefaultDelegatedDomain
-	userDelegatedDomain := hp.Annotations[delegatedDomainAnnotation]
-	if userDelegatedDomain != "" && r.AllowCustomDelegations && slices.Contains(r.AllowedDelegatedDomains, userDelegatedDomain) {
-		delegatedDomain = userDelegatedDomain
+// reconcileDelegationDNSEndpoint creates or updates the DNSEndpoint delegating the DNS-01 challenges of the HTTPProxy.
+// The records are generated only for the hostnames whose Certificate is issued with DNS-01 challenges,
+// and only while the primary DNSEndpoint of the HTTPProxy exists.
+func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context, hp *projectcontourv1.HTTPProxy, keep, published objectSet, log logr.Logger) error {
+	if !r.CreateDNSEndpoint || !r.CreateCertificate {
+		return nil
+	}
+	name := r.Prefix + hp.Name + "-delegation"
+	ok, err := r.hasPrimaryDNSEndpoint(ctx, hp, keep, published)
+	if err != nil || !ok {
+		return err
 	}
 
+	delegatedDomain := r.delegatedDomainOf(hp, log)
 	if delegatedDomain == "" {
 		return nil
 	}
 
-	if hp.Spec.VirtualHost == nil {
+	hostnames, err := proxyHostnames(hp)
+	if err != nil {
+		log.Error(err, "invalid hostnames")
+		keep.add(DNSEndpointKind, name)
 		return nil
 	}
-	fqdn := hp.Spec.VirtualHost.Fqdn
-	if len(fqdn) == 0 {
+	hostnames, err = r.dns01Hostnames(ctx, hp, hostnames, log)
+	if err != nil {
+		return err
+	}
+	if len(hostnames) == 0 {
 		return nil
 	}
 
+	policy, err := r.dnsRecordPolicy(hp)
+	if err != nil {
+		log.Error(err, "invalid DNS record policy")
+		keep.add(DNSEndpointKind, name)
+		return nil
+	}
+	keep.add(DNSEndpointKind, name)
+	if conflict, err := r.dnsEndpointConflict(ctx, hp, name); err != nil || conflict {
+		return err
+	}
+
 	obj := &unstructured.Unstructured{}
 	obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
-	obj.SetName(r.Prefix + hp.Name + "-delegation")
+	obj.SetName(name)
 	obj.SetNamespace(hp.Namespace)
 	obj.SetAnnotations(r.generateObjectAnnotations(hp))
-	obj.SetLabels(r.generateObjectLabels(hp))
+	// ACME servers resolve the challenge records in the public view
+	view := ""
+	if r.SplitHorizon {
+		view = dnsViewPublic
+	}
+	obj.SetLabels(r.dnsEndpointLabels(hp, view))
+	// a wildcard hostname shares the challenge record with its base domain
+	var endpoints []map[string]interface{}
+	challengeNames := make(map[string]bool)
+	for _, hostname := range hostnames {
+		challengeName := acmeChallengeName(hostname)
+		if challengeNames[challengeName] {
+			continue
+		}
+		challengeNames[challengeName] = true
+		target, err := r.DelegationTargets.target(hp, hostname, delegatedDomain)
+		if err != nil {
+			log.Error(err, "unable to render delegation target", "hostname", hostname)
+			return nil
+		}
+		endpoints = append(endpoints, makeDelegationEndpoint(hostname, target, policy.ttl)...)
+	}
 	obj.UnstructuredContent()["spec"] = map[string]interface{}{
-		"endpoints": makeDelegationEndpoint(fqdn, delegatedDomain),
+		"endpoints": endpoints,
 	}
 
 	if err := ctrl.SetControllerReference(hp, obj, r.Scheme); err != nil {
@@ -240,7 +598,7 @@ func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context
 
 	if err := r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
 		Force:        ptr.To(true),
-		FieldManager: "contour-plus",
+		FieldManager: fieldManager,
 	}); err != nil {
 		return err
 	}
@@ -249,50 +607,162 @@ func (r *HTTPProxyReconciler) reconcileDelegationDNSEndpoint(ctx context.Context
 	return nil
 }
 
-func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *projectcontourv1.HTTPProxy, log logr.Logger) error {
+// reconcileCertificate creates or updates the Certificate for the TLS secret of the HTTPProxy.
+// HTTPProxies referring to the same TLS secret get a single Certificate; see syncCertificate.
+func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *projectcontourv1.HTTPProxy, keep objectSet, log logr.Logger) error {
 	if !r.CreateCertificate {
 		return nil
 	}
-	if hp.Annotations[testACMETLSAnnotation] != "true" {
+	secretKey, ok := certificateSecretKey(hp)
+	if !ok {
 		return nil
 	}
-
-	vh := hp.Spec.VirtualHost
-	switch {
-	case vh == nil:
+	if secretKey.Namespace != hp.Namespace {
+		if !r.isSecretNamespaceAllowed(secretKey.Namespace) {
+			log.Info("namespace of secretName is not allowed", "secretName", hp.Spec.VirtualHost.TLS.SecretName)
+			return nil
+		}
+		allowed, err := r.isSourceNamespaceAllowed(ctx, hp.Namespace, secretKey.Namespace)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			log.Info("namespace is not allowed to refer to secretName", "secretName", hp.Spec.VirtualHost.TLS.SecretName)
+			r.Recorder.Eventf(hp, corev1.EventTypeWarning, "SecretNotAllowed",
+				"namespace %s is not allowed to refer to secrets in namespace %s; Certificate is not generated", hp.Namespace, secretKey.Namespace)
+			return nil
+		}
+		// the Certificate follows the defaults for the namespace where it is generated
+		r, err = r.forNamespace(ctx, secretKey.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := proxyHostnames(hp); err != nil {
+		log.Error(err, "invalid hostnames")
+		keep.addKind(CertificateKind)
 		return nil
-	case vh.Fqdn == "":
+	}
+	issuer, err := r.issuerOf(hp)
+	if err != nil {
+		log.Error(err, "invalid issuer")
+		keep.addKind(CertificateKind)
 		return nil
-	case vh.TLS == nil:
+	}
+	if issuer.name == "" {
+		log.Info("no issuer name")
 		return nil
-	case vh.TLS.SecretName == "":
+	}
+
+	name, err := r.syncCertificate(ctx, secretKey, hp, log)
+	if errors.Is(err, errInvalidCertificateSettings) {
+		keep.addKind(CertificateKind)
 		return nil
 	}
+	if errors.Is(err, errIssuerNotReady) {
+		r.Recorder.Event(hp, corev1.EventTypeWarning, "IssuerNotReady", err.Error())
+		keep.addKind(CertificateKind)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if secretKey.Namespace == hp.Namespace {
+		keep.add(CertificateKind, name)
+	}
+	return nil
+}
 
-	issuerName := r.DefaultIssuerName
-	issuerKind := r.DefaultIssuerKind
-	if name, ok := hp.Annotations[issuerNameAnnotation]; ok {
-		issuerName = name
-		issuerKind = IssuerKind
+// syncCertificate creates or updates the Certificate for the TLS secret identified by secretKey,
+// and returns its name.
+// cert-manager cannot manage a Secret with multiple Certificates, so the hostnames of all HTTPProxies referring to
+// the secret are merged into one Certificate. The other settings are taken from the first member.
+// The Certificate is named after the oldest HTTPProxy in the namespace of the secret, or after the secret
+// if the secret is only referred to from other namespaces.
+// If no HTTPProxy refers to the secret anymore, the Certificate is deleted and an empty name is returned.
+func (r *HTTPProxyReconciler) syncCertificate(ctx context.Context, secretKey client.ObjectKey, hp *projectcontourv1.HTTPProxy, log logr.Logger) (string, error) {
+	members, err := r.certificateMembers(ctx, secretKey, hp)
+	if err != nil {
+		return "", err
 	}
-	if name, ok := hp.Annotations[clusterIssuerNameAnnotation]; ok {
-		issuerName = name
-		issuerKind = ClusterIssuerKind
+	if len(members) == 0 {
+		return "", r.deleteStaleCertificates(ctx, secretKey, "", log)
 	}
 
-	if issuerName == "" {
-		log.Info("no issuer name")
-		return nil
+	primary := members[0]
+	var local, remote []*projectcontourv1.HTTPProxy
+	for _, member := range members {
+		if member.Namespace == secretKey.Namespace {
+			local = append(local, member)
+		} else {
+			remote = append(remote, member)
+		}
+	}
+	name := r.Prefix + secretKey.Name
+	if len(local) != 0 {
+		name = r.Prefix + primary.Name
+	}
+	conflict, err := r.certificateConflict(ctx, secretKey, name, local)
+	if err != nil {
+		return "", err
+	}
+	if conflict != nil {
+		for _, member := range members {
+			r.Recorder.Eventf(member, corev1.EventTypeWarning, "CertificateConflict",
+				"existing Certificate %s/%s conflicts with the Certificate for secret %s; Certificate is not generated",
+				conflict.GetNamespace(), conflict.GetName(), secretKey)
+		}
+		log.Info("Certificate conflicts with an existing one", "namespace", secretKey.Namespace, "name", name, "existing", conflict.GetName())
+		// another Certificate manages the secret, so the ones generated for the secret would only fight with it
+		if conflict.GetName() != name {
+			if err := r.deleteStaleCertificates(ctx, secretKey, "", log); err != nil {
+				return "", err
+			}
+		}
+		return "", errInvalidCertificateSettings
+	}
+	vh := primary.Spec.VirtualHost
+
+	hostnames, err := mergedHostnames(members)
+	if err != nil {
+		return "", err
+	}
+	if len(hostnames) > maxHostnames {
+		log.Error(fmt.Errorf("too many hostnames: %d, must be at most %d", len(hostnames), maxHostnames), "unable to merge hostnames for shared secret", "secret", secretKey)
+		return "", errInvalidCertificateSettings
+	}
+
+	requested, issuer, err := r.certificateIssuer(ctx, primary)
+	if errors.Is(err, errIssuerNotAllowed) {
+		r.Recorder.Eventf(primary, corev1.EventTypeWarning, "IssuerNotAllowed",
+			"issuer %s is not allowed in namespace %s; Certificate is not generated", requested, primary.Namespace)
+	}
+	if err != nil {
+		return "", err
+	}
+	if issuer != requested {
+		r.Recorder.Eventf(primary, corev1.EventTypeWarning, "IssuerNotAllowed",
+			"issuer %s is not allowed in namespace %s; using the default issuer %s", requested, primary.Namespace, issuer)
+	}
+	if err := r.checkIssuer(ctx, issuer, secretKey.Namespace); err != nil {
+		return "", err
+	}
+	// the requested issuers are compared so that IssuerPolicy does not make a conflict
+	for _, member := range members[1:] {
+		memberIssuer, err := r.issuerOf(member)
+		if err != nil || memberIssuer == requested {
+			continue
+		}
+		r.Recorder.Eventf(member, corev1.EventTypeWarning, "IssuerConflict",
+			"secret %s is shared with HTTPProxy %s/%s; using its issuer %s instead of %s",
+			secretKey, primary.Namespace, primary.Name, issuer, memberIssuer)
 	}
 
 	certificateSpec := map[string]interface{}{
-		"dnsNames":   []string{vh.Fqdn},
-		"secretName": vh.TLS.SecretName,
+		"dnsNames":   hostnames,
+		"secretName": secretKey.Name,
 		"commonName": vh.Fqdn,
-		"issuerRef": map[string]interface{}{
-			"kind": issuerKind,
-			"name": issuerName,
-		},
+		"issuerRef":  issuer.toMap(),
 		"usages": []string{
 			usageDigitalSignature,
 			usageKeyEncipherment,
@@ -303,60 +773,60 @@ func (r *HTTPProxyReconciler) reconcileCertificate(ctx context.Context, hp *proj
 	if r.CSRRevisionLimit > 0 {
 		certificateSpec["revisionHistoryLimit"] = r.CSRRevisionLimit
 	}
-	if value, ok := hp.Annotations[revisionHistoryLimitAnnotation]; ok {
+	if value, ok := primary.Annotations[revisionHistoryLimitAnnotation]; ok {
 		limit, err := strconv.ParseUint(value, 10, 32)
 		if err != nil {
 			log.Error(err, "invalid revisionHistoryLimit", "value", value)
-			return nil
+			return "", errInvalidCertificateSettings
 		}
 		certificateSpec["revisionHistoryLimit"] = limit
 	}
-	annotations := r.generateObjectAnnotations(hp)
-	labels := r.generateObjectLabels(hp)
+	annotations := r.generateObjectAnnotations(primary)
+	labels := r.generateObjectLabels(primary)
 	secretTemplate := map[string]interface{}{
 		"annotations": annotations,
 		"labels":      labels,
 	}
 	certificateSpec["secretTemplate"] = secretTemplate
 
-	if algorithm, ok := hp.Annotations[privateKeyAlgorithmAnnotation]; ok {
-		privateKeySpec := map[string]interface{}{
-			"algorithm": algorithm,
-		}
-		if value, ok := hp.Annotations[privateKeySizeAnnotation]; ok {
-			size, err := strconv.ParseUint(value, 10, 32)
-			if err == nil {
-				privateKeySpec["size"] = size
-			} else {
-				log.Error(err, "invalid privateKey size", "value", value)
-			}
-		}
-		certificateSpec["privateKey"] = privateKeySpec
+	if err := applyCertificateAnnotations(certificateSpec, primary.Annotations); err != nil {
+		log.Error(err, "invalid certificate annotations")
+		return "", errInvalidCertificateSettings
 	}
 
 	obj := &unstructured.Unstructured{}
 	obj.SetGroupVersionKind(certManagerGroupVersion.WithKind(CertificateKind))
-	obj.SetName(r.Prefix + hp.Name)
-	obj.SetNamespace(hp.Namespace)
+	obj.SetName(name)
+	obj.SetNamespace(secretKey.Namespace)
 	obj.UnstructuredContent()["spec"] = certificateSpec
 
+	objLabels := r.objectLabels(primary)
+	if len(remote) != 0 {
+		annotations = maps.Clone(annotations)
+		annotations[referringProxiesAnnotation] = joinProxyKeys(remote)
+		objLabels[crossNamespaceLabel] = "true"
+	}
 	obj.SetAnnotations(annotations)
-	obj.SetLabels(labels)
+	obj.SetLabels(objLabels)
+	obj.SetOwnerReferences(memberOwnerReferences(local))
 
-	err := ctrl.SetControllerReference(hp, obj, r.Scheme)
-	if err != nil {
-		return err
-	}
 	err = r.Patch(ctx, obj, client.Apply, &client.PatchOptions{
 		Force:        ptr.To(true),
-		FieldManager: "contour-plus",
+		FieldManager: fieldManager,
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	log.Info("Certificate successfully reconciled")
-	return nil
+	if err := r.syncTLSCertificateDelegation(ctx, obj, remote, log); err != nil {
+		return "", err
+	}
+	if err := r.deleteStaleCertificates(ctx, secretKey, name, log); err != nil {
+		return "", err
+	}
+
+	log.Info("Certificate successfully reconciled", "namespace", secretKey.Namespace, "name", name)
+	return name, nil
 }
 
 func (r *HTTPProxyReconciler) generateObjectAnnotations(hp *projectcontourv1.HTTPProxy) map[string]string {
@@ -369,6 +839,13 @@ func (r *HTTPProxyReconciler) generateObjectAnnotations(hp *projectcontourv1.HTT
 	return annotations
 }
 
+// objectLabels returns the labels of the objects generated for the HTTPProxy.
+func (r *HTTPProxyReconciler) objectLabels(hp *projectcontourv1.HTTPProxy) map[string]string {
+	labels := r.generateObjectLabels(hp)
+	labels[ownerLabel] = r.ownerID()
+	return labels
+}
+
 func (r *HTTPProxyReconciler) generateObjectLabels(hp *projectcontourv1.HTTPProxy) map[string]string {
 	labels := map[string]string{}
 	for _, key := range r.PropagatedLabels {
@@ -382,10 +859,9 @@ func (r *HTTPProxyReconciler) generateObjectLabels(hp *projectcontourv1.HTTPProx
 // SetupWithManager sets up the controller with the Manager.
 func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	listHPs := func(ctx context.Context, a client.Object) []reconcile.Request {
-		if a.GetNamespace() != r.ServiceKey.Namespace {
-			return nil
-		}
-		if a.GetName() != r.ServiceKey.Name {
+		svcKey := client.ObjectKeyFromObject(a)
+		isPrivate := r.PrivateServiceKey.Name != "" && svcKey == r.PrivateServiceKey
+		if !isPrivate && !r.isLoadBalancerService(svcKey) {
 			return nil
 		}
 
@@ -396,50 +872,107 @@ func (r *HTTPProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			return nil
 		}
 
-		requests := make([]reconcile.Request, len(hpList.Items))
-		for i, hp := range hpList.Items {
-			requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{
+		var requests []reconcile.Request
+		for _, hp := range hpList.Items {
+			// every HTTPProxy has records pointing at the private Service
+			if !isPrivate {
+				if key, err := r.serviceKeyFor(&hp); err != nil || key != svcKey {
+					continue
+				}
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
 				Name:      hp.Name,
 				Namespace: hp.Namespace,
-			}}
+			}})
 		}
 		return requests
 	}
 
 	b := ctrl.NewControllerManagedBy(mgr).
 		For(&projectcontourv1.HTTPProxy{}).
-		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(listHPs))
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.namespaceRequests))
+	if r.HostnameArbitration {
+		err := mgr.GetFieldIndexer().IndexField(context.Background(), &projectcontourv1.HTTPProxy{}, hostnameIndexField, indexHostnames)
+		if err != nil {
+			return err
+		}
+		// the other HTTPProxies using the same hostnames may win them when an HTTPProxy changes
+		b = b.Watches(&projectcontourv1.HTTPProxy{}, handler.EnqueueRequestsFromMapFunc(r.conflictRequests))
+	}
+	if r.CreateCertificate {
+		err := mgr.GetFieldIndexer().IndexField(context.Background(), &projectcontourv1.HTTPProxy{}, secretIndexField, indexSecret)
+		if err != nil {
+			return err
+		}
+	}
+	if r.DNSTargetSource != DNSTargetSourceHTTPProxyStatus || r.PrivateServiceKey.Name != "" {
+		b = b.Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(listHPs))
+	}
 	if r.CreateDNSEndpoint {
 		obj := &unstructured.Unstructured{}
 		obj.SetGroupVersionKind(externalDNSGroupVersion.WithKind(DNSEndpointKind))
 		b = b.Owns(obj)
 	}
+	// the solvers of issuers decide the delegation records as well
+	if r.CreateCertificate && (r.VerifyIssuer || r.CreateDNSEndpoint) {
+		for _, kind := range []string{IssuerKind, ClusterIssuerKind} {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(certManagerGroupVersion.WithKind(kind))
+			b = b.Watches(obj, handler.EnqueueRequestsFromMapFunc(r.issuerRequests))
+		}
+	}
+	if r.EnablePolicies {
+		b = b.Watches(&contourplusv1alpha1.ContourPlusPolicy{}, handler.EnqueueRequestsFromMapFunc(r.policyRequests)).
+			Watches(&contourplusv1alpha1.ClusterContourPlusPolicy{}, handler.EnqueueRequestsFromMapFunc(r.policyRequests))
+	}
 	if r.CreateCertificate {
+		// a Certificate shared by HTTPProxies is owned by or refers to all of them, so every one of them is notified of its changes
 		obj := &unstructured.Unstructured{}
 		obj.SetGroupVersionKind(certManagerGroupVersion.WithKind(CertificateKind))
-		b = b.Owns(obj)
+		b = b.Watches(obj, handler.EnqueueRequestsFromMapFunc(certificateRequests))
 	}
 	return b.Complete(r)
 }
 
-func makeEndpoints(hostname string, ips []net.IP) []map[string]interface{} {
-	ipv4Targets, ipv6Targets := ipsToTargets(ips)
+// loadBalancerTargets splits the load balancer ingress points into IP addresses and hostnames.
+func loadBalancerTargets(ingresses []corev1.LoadBalancerIngress) ([]net.IP, []string) {
+	var ips []net.IP
+	var hostnames []string
+	for _, ing := range ingresses {
+		if len(ing.IP) != 0 {
+			if ip := net.ParseIP(ing.IP); ip != nil {
+				ips = append(ips, ip)
+			}
+			continue
+		}
+		if len(ing.Hostname) != 0 {
+			hostnames = append(hostnames, ing.Hostname)
+		}
+	}
+	return ips, hostnames
+}
+
+// makeEndpoints builds the endpoints pointing hostname at the load balancer.
+// A/AAAA records are generated when the load balancer has IP addresses, only for the IP families allowed by the policy.
+// Otherwise, a CNAME record targeting the first load balancer hostname is generated
+// because a CNAME record cannot coexist with other records nor have multiple targets.
+func makeEndpoints(hostname string, ips []net.IP, lbHostnames []string, policy dnsRecordPolicy) []map[string]interface{} {
+	if len(ips) == 0 {
+		if len(lbHostnames) == 0 {
+			return nil
+		}
+		return []map[string]interface{}{
+			policy.endpoint(hostname, "CNAME", []string{strings.TrimSuffix(lbHostnames[0], ".")}),
+		}
+	}
+
+	ipv4Targets, ipv6Targets := ipsToTargets(policy.eligibleIPs(ips))
 	var endpoints []map[string]interface{}
 	if len(ipv4Targets) != 0 {
-		endpoints = append(endpoints, map[string]interface{}{
-			"dnsName":    hostname,
-			"targets":    ipv4Targets,
-			"recordType": "A",
-			"recordTTL":  3600,
-		})
+		endpoints = append(endpoints, policy.endpoint(hostname, "A", ipv4Targets))
 	}
 	if len(ipv6Targets) != 0 {
-		endpoints = append(endpoints, map[string]interface{}{
-			"dnsName":    hostname,
-			"targets":    ipv6Targets,
-			"recordType": "AAAA",
-			"recordTTL":  3600,
-		})
+		endpoints = append(endpoints, policy.endpoint(hostname, "AAAA", ipv6Targets))
 	}
 	return endpoints
 }
@@ -457,14 +990,14 @@ func ipsToTargets(ips []net.IP) ([]string, []string) {
 	return ipv4Targets, ipv6Targets
 }
 
-func makeDelegationEndpoint(hostname, delegatedDomain string) []map[string]interface{} {
-	fqdn := strings.Trim(hostname, ".")
+func makeDelegationEndpoint(hostname, target string, ttl int64) []map[string]interface{} {
+	challengeName := acmeChallengeName(hostname)
 	return []map[string]interface{}{
 		{
-			"dnsName":    "_acme-challenge." + fqdn,
-			"targets":    []string{"_acme-challenge." + fqdn + "." + delegatedDomain},
+			"dnsName":    challengeName,
+			"targets":    []string{target},
 			"recordType": "CNAME",
-			"recordTTL":  3600,
+			"recordTTL":  ttl,
 		},
 	}
 }
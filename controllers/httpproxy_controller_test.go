@@ -2,18 +2,29 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"slices"
+	"strings"
 	"testing"
+	"text/template"
 	"time"
 
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
@@ -211,6 +222,65 @@ func testHTTPProxyReconcile() {
 		Expect(dEndPoint["recordType"]).Should(Equal("CNAME"))
 	})
 
+	It("should not create delegation DNSEndpoint until the primary DNSEndpoint is created", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating loadbalancer service without an address")
+		serviceKey := client.ObjectKey{Namespace: testServiceKey.Namespace, Name: "pending-svc-" + randomString(5)}
+		svc := &corev1.Service{
+			ObjectMeta: ctrl.ObjectMeta{
+				Namespace: serviceKey.Namespace,
+				Name:      serviceKey.Name,
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Port: 8080}},
+				Type:  corev1.ServiceTypeLoadBalancer,
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), svc)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             serviceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+		delegationKey := client.ObjectKey{Namespace: ns, Name: hpKey.Name + "-delegation"}
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.Background(), hpKey, dnsEndpoint())).ShouldNot(Succeed())
+			g.Expect(k8sClient.Get(context.Background(), delegationKey, dnsEndpoint())).ShouldNot(Succeed())
+		}, 2*time.Second).Should(Succeed())
+
+		By("assigning an address to the service")
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+		Expect(k8sClient.Status().Update(context.Background(), svc)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), delegationKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+	})
+
 	It("should create delegation DNSEndpoint if requested via annotation", func() {
 		ns := testNamespacePrefix + randomString(10)
 		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
@@ -1183,197 +1253,2185 @@ func testHTTPProxyReconcile() {
 		Expect(deLabels["example.com/propagate-me"]).To(Equal("yes"))
 		Expect(deLabels).ToNot(HaveKey("example.com/do-not-propagate"))
 	})
-}
 
-func newDummyHTTPProxy(hpKey client.ObjectKey) *projectcontourv1.HTTPProxy {
-	return &projectcontourv1.HTTPProxy{
-		ObjectMeta: v1.ObjectMeta{
-			Namespace: hpKey.Namespace,
+	It("should create DNSEndpoint with the DNS record policy specified by annotations", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			DefaultRecordTTL:       300,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with DNS record policy annotations")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[recordTTLAnnotation] = "60"
+		hp.Annotations[providerSpecificAnnotation] = "external-dns.alpha.kubernetes.io/cloudflare-proxied=true"
+		hp.Annotations[setIdentifierAnnotation] = "primary"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint")
+		de := dnsEndpoint()
+		objKey := client.ObjectKey{
 			Name:      hpKey.Name,
-			Annotations: map[string]string{
-				testACMETLSAnnotation: "true",
-			},
-		},
-		Spec: projectcontourv1.HTTPProxySpec{
-			VirtualHost: &projectcontourv1.VirtualHost{
-				Fqdn: dnsName,
-				TLS:  &projectcontourv1.TLS{SecretName: testSecretName},
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["recordTTL"]).Should(BeEquivalentTo(60))
+		Expect(endPoint["setIdentifier"]).Should(Equal("primary"))
+		Expect(endPoint["providerSpecific"]).Should(Equal([]interface{}{
+			map[string]interface{}{
+				"name":  "external-dns.alpha.kubernetes.io/cloudflare-proxied",
+				"value": "true",
 			},
-			Routes: []projectcontourv1.Route{},
-		},
-	}
-}
+		}))
 
-func TestIsClassNameMatched(t *testing.T) {
-	tests := []struct {
-		name             string
-		ingressClassName string
-		hp               *projectcontourv1.HTTPProxy
-		want             bool
-	}{
-		{
-			name:             "Annotation is not set",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{},
-				},
-			},
-			want: false,
-		},
-		{
-			name:             "Spec is not set",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{},
-				},
-				Spec: projectcontourv1.HTTPProxySpec{},
-			},
-			want: false,
-		},
-		{
-			name:             "Both annotation are set",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						ingressClassNameAnnotation:        "class-name",
-						contourIngressClassNameAnnotation: "class-name",
-					},
-				},
-			},
-			want: true,
-		},
-		{
-			name:             "Both annotation and spec are set",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						ingressClassNameAnnotation:        "class-name",
-						contourIngressClassNameAnnotation: "class-name",
-					},
-				},
-				Spec: projectcontourv1.HTTPProxySpec{
-					IngressClassName: "class-name",
-				},
-			},
-			want: true,
-		},
-		{
-			name:             "Both annotation are set but not matched",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						ingressClassNameAnnotation:        "class-name",
-						contourIngressClassNameAnnotation: "wrong",
-					},
-				},
-			},
-			want: false,
-		},
-		{
-			name:             "Both annotation and spec are set but not matched",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						ingressClassNameAnnotation:        "class-name",
-						contourIngressClassNameAnnotation: "wrong",
-					},
-				},
-				Spec: projectcontourv1.HTTPProxySpec{
-					IngressClassName: "class-name",
-				},
-			},
-			want: false,
-		},
-		{
-			name:             fmt.Sprintf("Annotation %s is set", ingressClassNameAnnotation),
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						ingressClassNameAnnotation: "class-name",
-					},
-				},
-			},
-			want: true,
-		},
-		{
-			name:             fmt.Sprintf("Annotation %s is set but not matched", ingressClassNameAnnotation),
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						ingressClassNameAnnotation: "wrong",
-					},
-				},
-			},
-			want: false,
-		},
-		{
-			name:             fmt.Sprintf("Annotation %s is set", contourIngressClassNameAnnotation),
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						contourIngressClassNameAnnotation: "class-name",
-					},
-				},
-			},
-			want: true,
-		},
-		{
-			name:             fmt.Sprintf("Annotation %s is set but not matched", contourIngressClassNameAnnotation),
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{
-						contourIngressClassNameAnnotation: "wrong",
-					},
-				},
-			},
-			want: false,
-		},
-		{
-			name:             "Spec is set",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{},
-				},
-				Spec: projectcontourv1.HTTPProxySpec{
-					IngressClassName: "class-name",
-				},
-			},
-			want: true,
-		},
-		{
-			name:             "Spec is set but not matched",
-			ingressClassName: "class-name",
-			hp: &projectcontourv1.HTTPProxy{
-				ObjectMeta: v1.ObjectMeta{
-					Annotations: map[string]string{},
-				},
-				Spec: projectcontourv1.HTTPProxySpec{
-					IngressClassName: "wrong",
-				},
-			},
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r := &HTTPProxyReconciler{
-				IngressClassName: tt.ingressClassName,
-			}
-			if got := r.isClassNameMatched(tt.hp); got != tt.want {
-				t.Errorf("HTTPProxyReconciler.isClassNameMatched() = %v, want %v", got, tt.want)
-			}
-		})
+		By("getting delegation DNSEndpoint")
+		dde := dnsEndpoint()
+		dObjKey := client.ObjectKey{
+			Name:      hpKey.Name + "-delegation",
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dde)
+		}, 5*time.Second).Should(Succeed())
+		ddeSpec := dde.UnstructuredContent()["spec"].(map[string]interface{})
+		dEndPoint := ddeSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(dEndPoint["recordTTL"]).Should(BeEquivalentTo(60))
+		Expect(dEndPoint).ShouldNot(HaveKey("providerSpecific"))
+	})
+
+	It("should not create DNSEndpoint if the DNS record policy annotations are invalid", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateDNSEndpoint: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with an invalid TTL")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[recordTTLAnnotation] = "-1"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint does not exist")
+		time.Sleep(time.Second)
+		endpointList := dnsEndpointList()
+		Expect(k8sClient.List(context.Background(), endpointList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
+		Expect(endpointList.Items).Should(BeEmpty())
+	})
+
+	It("should create DNSEndpoints and Certificate for a wildcard FQDN", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with a wildcard FQDN")
+		wildcardName := "*.apps.example.com"
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.VirtualHost.Fqdn = wildcardName
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint")
+		de := dnsEndpoint()
+		objKey := client.ObjectKey{
+			Name:      hpKey.Name,
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["dnsName"]).Should(Equal(wildcardName))
+		Expect(endPoint["recordType"]).Should(Equal("A"))
+
+		By("getting delegation DNSEndpoint for the base domain")
+		dde := dnsEndpoint()
+		dObjKey := client.ObjectKey{
+			Name:      hpKey.Name + "-delegation",
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dde)
+		}, 5*time.Second).Should(Succeed())
+		ddeSpec := dde.UnstructuredContent()["spec"].(map[string]interface{})
+		dEndPoint := ddeSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(dEndPoint["dnsName"]).Should(Equal("_acme-challenge.apps.example.com"))
+		Expect(dEndPoint["targets"]).Should(Equal([]interface{}{"_acme-challenge.apps.example.com." + testDelegationName}))
+
+		By("getting Certificate")
+		crt := certificate()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}).Should(Succeed())
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{wildcardName}))
+	})
+
+	It("should not create DNSEndpoint and Certificate for a wildcard FQDN that cannot be issued", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with a wildcard directly under a TLD")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.VirtualHost.Fqdn = "*.com"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint and Certificate do not exist")
+		time.Sleep(time.Second)
+		endpointList := dnsEndpointList()
+		Expect(k8sClient.List(context.Background(), endpointList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
+		Expect(endpointList.Items).Should(BeEmpty())
+
+		crtList := certificateList()
+		Expect(k8sClient.List(context.Background(), crtList, client.InNamespace(ns))).ShouldNot(HaveOccurred())
+		Expect(crtList.Items).Should(BeEmpty())
+	})
+
+	It("should create DNS records and Certificate for additional hostnames", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with additional hostnames")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[additionalHostnamesAnnotation] = "www.example.com,legacy.example.net"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint")
+		de := dnsEndpoint()
+		objKey := client.ObjectKey{
+			Name:      hpKey.Name,
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoints := deSpec["endpoints"].([]interface{})
+		Expect(endPoints).Should(HaveLen(3))
+		var dnsNames []interface{}
+		for _, ep := range endPoints {
+			dnsNames = append(dnsNames, ep.(map[string]interface{})["dnsName"])
+		}
+		Expect(dnsNames).Should(Equal([]interface{}{dnsName, "www.example.com", "legacy.example.net"}))
+
+		By("getting delegation DNSEndpoint")
+		dde := dnsEndpoint()
+		dObjKey := client.ObjectKey{
+			Name:      hpKey.Name + "-delegation",
+			Namespace: hpKey.Namespace,
+		}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dde)
+		}, 5*time.Second).Should(Succeed())
+		ddeSpec := dde.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(ddeSpec["endpoints"]).Should(HaveLen(3))
+
+		By("getting Certificate")
+		crt := certificate()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), objKey, crt)
+		}).Should(Succeed())
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName, "www.example.com", "legacy.example.net"}))
+		Expect(crtSpec["commonName"]).Should(Equal(dnsName))
+	})
+
+	It("should create DNSEndpoint pointing at the Service selected by annotation", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating another loadbalancer service")
+		internalServiceKey := client.ObjectKey{Namespace: testServiceKey.Namespace, Name: "internal-svc-" + randomString(5)}
+		svc := &corev1.Service{
+			ObjectMeta: ctrl.ObjectMeta{
+				Namespace: internalServiceKey.Namespace,
+				Name:      internalServiceKey.Name,
+			},
+			Spec: corev1.ServiceSpec{
+				Ports: []corev1.ServicePort{{Port: 8080}},
+				Type:  corev1.ServiceTypeLoadBalancer,
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), svc)).ShouldNot(HaveOccurred())
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}
+		Expect(k8sClient.Status().Update(context.Background(), svc)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey: testServiceKey,
+			ServiceKeys: map[string]client.ObjectKey{
+				"internal": internalServiceKey,
+			},
+			CreateDNSEndpoint: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxies")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[serviceNameAnnotation] = "internal"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		unknownKey := client.ObjectKey{Name: "bar", Namespace: ns}
+		unknown := newDummyHTTPProxy(unknownKey)
+		unknown.Annotations[serviceNameAnnotation] = "unknown"
+		Expect(k8sClient.Create(context.Background(), unknown)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint pointing at the selected service")
+		de := dnsEndpoint()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["targets"]).Should(Equal([]interface{}{"10.0.0.1"}))
+
+		By("updating the IP address of the selected service")
+		svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.2"}}
+		Expect(k8sClient.Status().Update(context.Background(), svc)).ShouldNot(HaveOccurred())
+		Eventually(func() interface{} {
+			de := dnsEndpoint()
+			if err := k8sClient.Get(context.Background(), hpKey, de); err != nil {
+				return nil
+			}
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			return deSpec["endpoints"].([]interface{})[0].(map[string]interface{})["targets"]
+		}, 5*time.Second).Should(Equal([]interface{}{"10.0.0.2"}))
+
+		By("confirming that DNSEndpoint for the unknown service does not exist")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), unknownKey, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should create DNSEndpoint from the HTTPProxy status if requested", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			DNSTargetSource:   DNSTargetSourceHTTPProxyStatus,
+			CreateDNSEndpoint: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint does not exist before the status is published")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		By("publishing the load balancer address to the HTTPProxy status")
+		hp.Status.CurrentStatus = "valid"
+		hp.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "10.0.0.3"}}
+		Expect(k8sClient.Status().Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoint")
+		de := dnsEndpoint()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["targets"]).Should(Equal([]interface{}{"10.0.0.3"}))
+	})
+
+	It("should create and remove resources according to the validity of HTTPProxy", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			InvalidHTTPProxyPolicy: InvalidHTTPProxyPolicyRemove,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy not yet validated by Contour")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint and Certificate do not exist")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), hpKey, certificate())).ShouldNot(Succeed())
+
+		By("marking HTTPProxy valid")
+		hp.Status.CurrentStatus = "valid"
+		Expect(k8sClient.Status().Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+
+		By("marking HTTPProxy invalid")
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		hp.Status.CurrentStatus = "invalid"
+		hp.Status.Description = "duplicate vhost"
+		Expect(k8sClient.Status().Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint and Certificate are removed")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should delete generated resources when HTTPProxy stops qualifying", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+		dObjKey := client.ObjectKey{Name: hpKey.Name + "-delegation", Namespace: ns}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		crt := certificate()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, crt)
+		}, 5*time.Second).Should(Succeed())
+		Expect(crt.GetLabels()).Should(HaveKeyWithValue(ownerLabel, defaultOwnerID))
+
+		By("removing the tls-acme annotation")
+		hp := &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		delete(hp.Annotations, testACMETLSAnnotation)
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that Certificate and delegation DNSEndpoint are deleted")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), dObjKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), hpKey, dnsEndpoint())).Should(Succeed())
+
+		By("excluding HTTPProxy")
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		hp.Annotations[excludeAnnotation] = "true"
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint is deleted")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should not delete resources generated by another instance", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			IngressClassName:  "class-a",
+			OwnerID:           "instance-a",
+			CreateDNSEndpoint: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy of another class and DNSEndpoint generated by another instance")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.IngressClassName = "class-b"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		de := dnsEndpoint()
+		de.SetName(hpKey.Name)
+		de.SetNamespace(ns)
+		de.SetLabels(map[string]string{ownerLabel: "instance-b"})
+		Expect(ctrl.SetControllerReference(hp, de, scm)).ShouldNot(HaveOccurred())
+		de.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": []interface{}{},
+		}
+		Expect(k8sClient.Create(context.Background(), de)).ShouldNot(HaveOccurred())
+
+		By("confirming that DNSEndpoint remains")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 2*time.Second).Should(Succeed())
+	})
+
+	It("should leave resources of other instances and delete resources generated before the owner label", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			IngressClassName:  "class-a",
+			CreateDNSEndpoint: true,
+		})).ShouldNot(HaveOccurred())
+
+		By("creating HTTPProxy of another class and DNSEndpoint generated by the instance serving the class")
+		otherKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		other := newDummyHTTPProxy(otherKey)
+		other.Spec.IngressClassName = "class-b"
+		Expect(k8sClient.Create(context.Background(), other)).ShouldNot(HaveOccurred())
+		de := dnsEndpoint()
+		de.SetName(otherKey.Name)
+		de.SetNamespace(ns)
+		de.SetLabels(map[string]string{ownerLabel: "instance-b"})
+		Expect(ctrl.SetControllerReference(other, de, scm)).ShouldNot(HaveOccurred())
+		de.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": []interface{}{},
+		}
+		Expect(k8sClient.Create(context.Background(), de)).ShouldNot(HaveOccurred())
+
+		By("creating excluded HTTPProxy and DNSEndpoint generated without the owner label")
+		legacyKey := client.ObjectKey{Name: "bar", Namespace: ns}
+		legacy := newDummyHTTPProxy(legacyKey)
+		legacy.Spec.IngressClassName = "class-a"
+		legacy.Annotations[excludeAnnotation] = "true"
+		Expect(k8sClient.Create(context.Background(), legacy)).ShouldNot(HaveOccurred())
+		de = dnsEndpoint()
+		de.SetName(legacyKey.Name)
+		de.SetNamespace(ns)
+		Expect(ctrl.SetControllerReference(legacy, de, scm)).ShouldNot(HaveOccurred())
+		de.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": []interface{}{},
+		}
+		Expect(k8sClient.Patch(context.Background(), de, client.Apply, &client.PatchOptions{
+			FieldManager: fieldManager,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("confirming that only the DNSEndpoint without the owner label is deleted")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), legacyKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), otherKey, dnsEndpoint())
+		}, 2*time.Second).Should(Succeed())
+	})
+
+	It("should delete resources when the ingress class changes", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			IngressClassName:  "class-a",
+			CreateDNSEndpoint: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy of the class")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.IngressClassName = "class-a"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("moving HTTPProxy to a class served by no instance")
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		hp.Spec.IngressClassName = "class-c"
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should sweep resources of disabled kinds", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating DNSEndpoints")
+		generated := dnsEndpoint()
+		generated.SetName("generated")
+		generated.SetNamespace(ns)
+		generated.SetLabels(map[string]string{ownerLabel: defaultOwnerID})
+		generated.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": []interface{}{},
+		}
+		Expect(k8sClient.Create(context.Background(), generated)).ShouldNot(HaveOccurred())
+
+		other := dnsEndpoint()
+		other.SetName("other")
+		other.SetNamespace(ns)
+		other.UnstructuredContent()["spec"] = map[string]interface{}{
+			"endpoints": []interface{}{},
+		}
+		Expect(k8sClient.Create(context.Background(), other)).ShouldNot(HaveOccurred())
+
+		sweeper := &OrphanSweeper{
+			Client:            k8sClient,
+			Reader:            k8sClient,
+			Log:               logf.Log.WithName("sweeper"),
+			CreateCertificate: true,
+			DryRun:            true,
+		}
+
+		By("sweeping in dry-run mode")
+		Expect(sweeper.Sweep(context.Background())).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(generated), dnsEndpoint())).Should(Succeed())
+
+		By("sweeping")
+		sweeper.DryRun = false
+		Expect(sweeper.Sweep(context.Background())).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(generated), dnsEndpoint())).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(other), dnsEndpoint())).Should(Succeed())
+	})
+
+	It("should merge HTTPProxies sharing a TLS secret into one Certificate", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		prefix := "test-"
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			Prefix:            prefix,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxies sharing a TLS secret")
+		hpKeyA := client.ObjectKey{Name: "a", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKeyA))).ShouldNot(HaveOccurred())
+
+		hpKeyB := client.ObjectKey{Name: "b", Namespace: ns}
+		hpB := newDummyHTTPProxy(hpKeyB)
+		hpB.Spec.VirtualHost.Fqdn = "b.example.com"
+		hpB.Annotations[clusterIssuerNameAnnotation] = "other-issuer"
+		Expect(k8sClient.Create(context.Background(), hpB)).ShouldNot(HaveOccurred())
+
+		By("getting the merged Certificate")
+		crtKey := client.ObjectKey{Name: prefix + hpKeyA.Name, Namespace: ns}
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), crtKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName, "b.example.com"}))
+			g.Expect(crtSpec["commonName"]).Should(Equal(dnsName))
+			g.Expect(crtSpec["issuerRef"]).Should(Equal(map[string]interface{}{
+				"kind": IssuerKind,
+				"name": "test-issuer",
+			}))
+			g.Expect(crt.GetOwnerReferences()).Should(HaveLen(2))
+		}, 5*time.Second).Should(Succeed())
+
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), client.ObjectKey{Name: prefix + hpKeyB.Name, Namespace: ns}, certificate())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		By("reporting the issuer conflict")
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(ns))).Should(Succeed())
+			var reasons []string
+			for _, ev := range events.Items {
+				if ev.InvolvedObject.Name == hpKeyB.Name {
+					reasons = append(reasons, ev.Reason)
+				}
+			}
+			g.Expect(reasons).Should(ContainElement("IssuerConflict"))
+		}, 5*time.Second).Should(Succeed())
+
+		By("moving the second HTTPProxy to another TLS secret")
+		Expect(k8sClient.Get(context.Background(), hpKeyB, hpB)).Should(Succeed())
+		hpB.Spec.VirtualHost.TLS.SecretName = "another-secret"
+		Expect(k8sClient.Update(context.Background(), hpB)).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), crtKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
+			g.Expect(crt.GetOwnerReferences()).Should(HaveLen(1))
+		}, 5*time.Second).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), client.ObjectKey{Name: prefix + hpKeyB.Name, Namespace: ns}, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["secretName"]).Should(Equal("another-secret"))
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should create Certificate and TLSCertificateDelegation for namespaced secretName", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+		certNS := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: certNS},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		prefix := "test-"
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			Prefix:                  prefix,
+			DefaultIssuerName:       "test-issuer",
+			DefaultIssuerKind:       ClusterIssuerKind,
+			CreateCertificate:       true,
+			AllowedSecretNamespaces: []string{certNS},
+			SecretNamespacePolicy: &SecretNamespacePolicy{
+				Rules: []SecretNamespacePolicyRule{{SecretNamespaces: []string{certNS}, Namespaces: []string{ns}}},
+			},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy referring to a secret in another namespace")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.VirtualHost.TLS.SecretName = certNS + "/wildcard"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting Certificate in the namespace of the secret")
+		crtKey := client.ObjectKey{Name: prefix + "wildcard", Namespace: certNS}
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), crtKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["secretName"]).Should(Equal("wildcard"))
+			g.Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
+			g.Expect(crt.GetAnnotations()).Should(HaveKeyWithValue(referringProxiesAnnotation, ns+"/foo"))
+		}, 5*time.Second).Should(Succeed())
+
+		By("getting TLSCertificateDelegation")
+		tcd := &projectcontourv1.TLSCertificateDelegation{}
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), crtKey, tcd)
+		}, 5*time.Second).Should(Succeed())
+		Expect(tcd.Spec.Delegations).Should(Equal([]projectcontourv1.CertificateDelegation{
+			{SecretName: "wildcard", TargetNamespaces: []string{ns}},
+		}))
+
+		By("ensuring no Certificate is created in the namespace of HTTPProxy")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), client.ObjectKey{Name: prefix + hpKey.Name, Namespace: ns}, certificate())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		By("referring to a secret in a namespace not allowed")
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).Should(Succeed())
+		hp.Spec.VirtualHost.TLS.SecretName = "other/wildcard"
+		Expect(k8sClient.Update(context.Background(), hp)).Should(Succeed())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), crtKey, certificate())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), crtKey, &projectcontourv1.TLSCertificateDelegation{})
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should protect secrets in other namespaces", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns, Labels: map[string]string{"certs": "allowed"}},
+		})).ShouldNot(HaveOccurred())
+		otherNS := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: otherNS},
+		})).ShouldNot(HaveOccurred())
+		certNS := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: certNS},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating Certificate not generated by contour-plus")
+		external := certificate()
+		external.SetName("external")
+		external.SetNamespace(certNS)
+		external.UnstructuredContent()["spec"] = map[string]interface{}{
+			"secretName": "external-tls",
+			"dnsNames":   []interface{}{dnsName},
+			"issuerRef":  map[string]interface{}{"kind": ClusterIssuerKind, "name": "test-issuer"},
+		}
+		Expect(k8sClient.Create(context.Background(), external)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			DefaultIssuerName:       "test-issuer",
+			DefaultIssuerKind:       ClusterIssuerKind,
+			CreateCertificate:       true,
+			AllowedSecretNamespaces: []string{certNS},
+			SecretNamespacePolicy: &SecretNamespacePolicy{
+				Rules: []SecretNamespacePolicyRule{{
+					SecretNamespaces:  []string{certNS},
+					NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"certs": "allowed"}},
+				}},
+			},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxies referring to a secret in another namespace")
+		allowedKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		allowed := newDummyHTTPProxy(allowedKey)
+		allowed.Spec.VirtualHost.TLS.SecretName = certNS + "/wildcard"
+		Expect(k8sClient.Create(context.Background(), allowed)).ShouldNot(HaveOccurred())
+		deniedKey := client.ObjectKey{Name: "bar", Namespace: otherNS}
+		denied := newDummyHTTPProxy(deniedKey)
+		denied.Spec.VirtualHost.Fqdn = "denied.example.com"
+		denied.Spec.VirtualHost.TLS.SecretName = certNS + "/wildcard"
+		Expect(k8sClient.Create(context.Background(), denied)).ShouldNot(HaveOccurred())
+
+		By("confirming that only the allowed namespace is merged and delegated")
+		crtKey := client.ObjectKey{Name: "wildcard", Namespace: certNS}
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), crtKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["dnsNames"]).Should(Equal([]interface{}{dnsName}))
+			g.Expect(crt.GetAnnotations()).Should(HaveKeyWithValue(referringProxiesAnnotation, ns+"/foo"))
+		}, 5*time.Second).Should(Succeed())
+		tcd := &projectcontourv1.TLSCertificateDelegation{}
+		Expect(k8sClient.Get(context.Background(), crtKey, tcd)).Should(Succeed())
+		Expect(tcd.Spec.Delegations[0].TargetNamespaces).Should(Equal([]string{ns}))
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(otherNS))).Should(Succeed())
+			g.Expect(events.Items).Should(ContainElement(HaveField("Reason", "SecretNotAllowed")))
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that the sweeper keeps the Certificate referred to from other namespaces")
+		sweeper := &OrphanSweeper{
+			Client:            k8sClient,
+			Reader:            k8sClient,
+			Log:               logf.Log.WithName("sweeper"),
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+		}
+		Expect(sweeper.Sweep(context.Background())).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Get(context.Background(), crtKey, certificate())).Should(Succeed())
+
+		By("referring to a secret managed by another Certificate")
+		Expect(k8sClient.Get(context.Background(), allowedKey, allowed)).Should(Succeed())
+		allowed.Spec.VirtualHost.TLS.SecretName = certNS + "/external-tls"
+		Expect(k8sClient.Update(context.Background(), allowed)).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(ns))).Should(Succeed())
+			g.Expect(events.Items).Should(ContainElement(HaveField("Reason", "CertificateConflict")))
+		}, 5*time.Second).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), client.ObjectKey{Name: "external-tls", Namespace: certNS}, certificate())
+		}, 2*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should create Certificate for an allowed external issuer", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			AllowedIssuerKinds: []schema.GroupKind{
+				{Group: "certmanager.step.sm", Kind: "StepIssuer"},
+			},
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with external issuer annotations")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[issuerNameAnnotation] = "step-issuer"
+		hp.Annotations[issuerKindAnnotation] = "StepIssuer"
+		hp.Annotations[issuerGroupAnnotation] = "certmanager.step.sm"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting Certificate")
+		crt := certificate()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, crt)
+		}, 5*time.Second).Should(Succeed())
+		crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+		Expect(crtSpec["issuerRef"]).Should(Equal(map[string]interface{}{
+			"group": "certmanager.step.sm",
+			"kind":  "StepIssuer",
+			"name":  "step-issuer",
+		}))
+
+		By("creating HTTPProxy with an external issuer not allowed")
+		hpKey2 := client.ObjectKey{Name: "bar", Namespace: ns}
+		hp2 := newDummyHTTPProxy(hpKey2)
+		hp2.Spec.VirtualHost.TLS.SecretName = "bar-tls"
+		hp2.Annotations[issuerNameAnnotation] = "vault"
+		hp2.Annotations[issuerKindAnnotation] = "VaultIssuer"
+		hp2.Annotations[issuerGroupAnnotation] = "vault.example.com"
+		Expect(k8sClient.Create(context.Background(), hp2)).ShouldNot(HaveOccurred())
+
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey2, certificate())
+		}, 2*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should not verify external issuers", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:         testServiceKey,
+			DefaultIssuerName:  "step-issuer",
+			DefaultIssuerKind:  "StepClusterIssuer",
+			DefaultIssuerGroup: "certmanager.step.sm",
+			AllowedIssuerKinds: []schema.GroupKind{
+				{Group: "certmanager.step.sm", Kind: "StepClusterIssuer"},
+			},
+			CreateCertificate: true,
+			VerifyIssuer:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		By("getting Certificate without verifying the external issuer")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should wait for the issuer to get ready", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		issuerName := "issuer-" + randomString(10)
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: issuerName,
+			DefaultIssuerKind: ClusterIssuerKind,
+			CreateCertificate: true,
+			VerifyIssuer:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		By("confirming that Certificate is not created without the issuer")
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(ns))).Should(Succeed())
+			var reasons []string
+			for _, ev := range events.Items {
+				reasons = append(reasons, ev.Reason)
+			}
+			g.Expect(reasons).Should(ContainElement("IssuerNotReady"))
+		}, 5*time.Second).Should(Succeed())
+
+		By("creating ClusterIssuer")
+		issuer := &unstructured.Unstructured{}
+		issuer.SetGroupVersionKind(certManagerGroupVersion.WithKind(ClusterIssuerKind))
+		issuer.SetName(issuerName)
+		issuer.UnstructuredContent()["spec"] = map[string]interface{}{
+			"selfSigned": map[string]interface{}{},
+		}
+		Expect(k8sClient.Create(context.Background(), issuer)).ShouldNot(HaveOccurred())
+
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		By("making ClusterIssuer ready")
+		issuer.UnstructuredContent()["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":               "Ready",
+					"status":             "True",
+					"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+				},
+			},
+		}
+		Expect(k8sClient.Status().Update(context.Background(), issuer)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should restrict issuers by issuer policy", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns, Labels: map[string]string{"tier": "premium"}},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		policy := &IssuerPolicy{
+			Rules: []IssuerPolicyRule{
+				{
+					NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"tier": "premium"}},
+					Issuers:           []IssuerPolicyIssuer{{Kind: ClusterIssuerKind, Name: "commercial-ca"}},
+				},
+			},
+			DisallowedAction: IssuerPolicyActionFallback,
+		}
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: ClusterIssuerKind,
+			CreateCertificate: true,
+			IssuerPolicy:      policy,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with an allowed issuer")
+		hpKey := client.ObjectKey{Name: "allowed", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Spec.VirtualHost.TLS.SecretName = "allowed-tls"
+		hp.Annotations[clusterIssuerNameAnnotation] = "commercial-ca"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "commercial-ca"))
+		}, 5*time.Second).Should(Succeed())
+
+		By("creating HTTPProxy with an issuer not allowed")
+		hpKey2 := client.ObjectKey{Name: "disallowed", Namespace: ns}
+		hp2 := newDummyHTTPProxy(hpKey2)
+		hp2.Spec.VirtualHost.TLS.SecretName = "disallowed-tls"
+		hp2.Annotations[clusterIssuerNameAnnotation] = "other-ca"
+		Expect(k8sClient.Create(context.Background(), hp2)).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey2, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "test-issuer"))
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should apply issuer policy to the default issuer of ContourPlusPolicy", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating ContourPlusPolicy with a default issuer not allowed by the issuer policy")
+		policy := &contourplusv1alpha1.ContourPlusPolicy{
+			ObjectMeta: ctrl.ObjectMeta{Namespace: ns, Name: contourplusv1alpha1.PolicyName},
+			Spec: contourplusv1alpha1.ContourPlusPolicySpec{
+				DefaultIssuerName: "commercial-ca",
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), policy)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		issuerPolicy := &IssuerPolicy{
+			Rules: []IssuerPolicyRule{
+				{
+					NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"tier": "premium"}},
+					Issuers:           []IssuerPolicyIssuer{{Kind: ClusterIssuerKind, Name: "commercial-ca"}},
+				},
+			},
+			DisallowedAction: IssuerPolicyActionFallback,
+		}
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: ClusterIssuerKind,
+			CreateCertificate: true,
+			EnablePolicies:    true,
+			IssuerPolicy:      issuerPolicy,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy without annotations for the issuer")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "test-issuer"))
+		}, 5*time.Second).Should(Succeed())
+
+		By("allowing the default issuer of ContourPlusPolicy in the namespace")
+		namespace := &corev1.Namespace{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Name: ns}, namespace)).ShouldNot(HaveOccurred())
+		namespace.Labels = map[string]string{"tier": "premium"}
+		Expect(k8sClient.Update(context.Background(), namespace)).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "commercial-ca"))
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should inherit default annotations from Namespace", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{
+				Name: ns,
+				Annotations: map[string]string{
+					clusterIssuerNameAnnotation:    "namespace-issuer",
+					revisionHistoryLimitAnnotation: "3",
+				},
+			},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: IssuerKind,
+			CSRRevisionLimit:  1,
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy without annotations for the issuer")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "namespace-issuer"))
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("kind", ClusterIssuerKind))
+			g.Expect(crtSpec["revisionHistoryLimit"]).Should(BeEquivalentTo(3))
+		}, 5*time.Second).Should(Succeed())
+
+		By("overriding the issuer by HTTPProxy annotation")
+		hp := &projectcontourv1.HTTPProxy{}
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		hp.Annotations[issuerNameAnnotation] = "proxy-issuer"
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "proxy-issuer"))
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("kind", IssuerKind))
+		}, 5*time.Second).Should(Succeed())
+
+		By("excluding the Namespace")
+		namespace := &corev1.Namespace{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Name: ns}, namespace)).ShouldNot(HaveOccurred())
+		namespace.Annotations[excludeAnnotation] = "true"
+		Expect(k8sClient.Update(context.Background(), namespace)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, certificate())
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should override defaults by ContourPlusPolicy within guardrails", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating policies")
+		guardrails := &contourplusv1alpha1.ClusterContourPlusPolicy{
+			ObjectMeta: ctrl.ObjectMeta{Name: contourplusv1alpha1.PolicyName},
+			Spec: contourplusv1alpha1.ClusterContourPlusPolicySpec{
+				MaxDNSRecordTTL: ptr.To[int32](600),
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), guardrails)).ShouldNot(HaveOccurred())
+		defer func() {
+			Expect(k8sClient.Delete(context.Background(), guardrails)).ShouldNot(HaveOccurred())
+		}()
+		policy := &contourplusv1alpha1.ContourPlusPolicy{
+			ObjectMeta: ctrl.ObjectMeta{Namespace: ns, Name: contourplusv1alpha1.PolicyName},
+			Spec: contourplusv1alpha1.ContourPlusPolicySpec{
+				DefaultIssuerName: "tenant-issuer",
+				DefaultIssuerKind: IssuerKind,
+				DNSRecordTTL:      ptr.To[int32](3000),
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), policy)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: ClusterIssuerKind,
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+			EnablePolicies:    true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(hpKey))).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			crt := certificate()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, crt)).Should(Succeed())
+			crtSpec := crt.UnstructuredContent()["spec"].(map[string]interface{})
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("name", "tenant-issuer"))
+			g.Expect(crtSpec["issuerRef"]).Should(HaveKeyWithValue("kind", IssuerKind))
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func(g Gomega) {
+			de := dnsEndpoint()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, de)).Should(Succeed())
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+			g.Expect(endPoint["recordTTL"]).Should(BeEquivalentTo(600))
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming the status of ContourPlusPolicy")
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(policy), policy)).Should(Succeed())
+			g.Expect(policy.Status.Effective).ShouldNot(BeNil())
+			g.Expect(policy.Status.Effective.DefaultIssuerName).Should(Equal("tenant-issuer"))
+			g.Expect(policy.Status.Effective.DNSRecordTTL).Should(BeEquivalentTo(600))
+			cond := meta.FindStatusCondition(policy.Status.Conditions, contourplusv1alpha1.ContourPlusPolicyConditionAccepted)
+			g.Expect(cond).ShouldNot(BeNil())
+			g.Expect(cond.Status).Should(Equal(v1.ConditionFalse))
+			g.Expect(cond.Message).Should(ContainSubstring("dnsRecordTTL"))
+		}, 5*time.Second).Should(Succeed())
+
+		By("relaxing the guardrails")
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(guardrails), guardrails)).ShouldNot(HaveOccurred())
+		guardrails.Spec.MaxDNSRecordTTL = nil
+		Expect(k8sClient.Update(context.Background(), guardrails)).ShouldNot(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			de := dnsEndpoint()
+			g.Expect(k8sClient.Get(context.Background(), hpKey, de)).Should(Succeed())
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+			g.Expect(endPoint["recordTTL"]).Should(BeEquivalentTo(3000))
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(policy), policy)).Should(Succeed())
+			g.Expect(meta.IsStatusConditionTrue(policy.Status.Conditions, contourplusv1alpha1.ContourPlusPolicyConditionAccepted)).Should(BeTrue())
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should refuse hostnames not owned by the namespace", func() {
+		ownerNs := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ownerNs},
+		})).ShouldNot(HaveOccurred())
+		otherNs := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: otherNs},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating ClusterContourPlusPolicy")
+		guardrails := &contourplusv1alpha1.ClusterContourPlusPolicy{
+			ObjectMeta: ctrl.ObjectMeta{Name: contourplusv1alpha1.PolicyName},
+			Spec: contourplusv1alpha1.ClusterContourPlusPolicySpec{
+				DomainOwners: []contourplusv1alpha1.DomainOwner{
+					{Suffix: dnsName, Namespaces: []string{ownerNs}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), guardrails)).ShouldNot(HaveOccurred())
+		defer func() {
+			Expect(k8sClient.Delete(context.Background(), guardrails)).ShouldNot(HaveOccurred())
+		}()
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			DefaultIssuerName: "test-issuer",
+			DefaultIssuerKind: ClusterIssuerKind,
+			CreateDNSEndpoint: true,
+			CreateCertificate: true,
+			EnablePolicies:    true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy in the owner namespace")
+		ownerKey := client.ObjectKey{Name: "foo", Namespace: ownerNs}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(ownerKey))).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), ownerKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), ownerKey, certificate())
+		}, 5*time.Second).Should(Succeed())
+
+		By("creating HTTPProxy in another namespace")
+		otherKey := client.ObjectKey{Name: "foo", Namespace: otherNs}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(otherKey))).ShouldNot(HaveOccurred())
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(otherNs))).Should(Succeed())
+			g.Expect(events.Items).Should(ContainElement(HaveField("Reason", "DomainNotAllowed")))
+		}, 5*time.Second).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), otherKey, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), otherKey, certificate())).ShouldNot(Succeed())
+
+		By("allowing the other namespace by label")
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(guardrails), guardrails)).ShouldNot(HaveOccurred())
+		guardrails.Spec.DomainOwners[0].NamespaceSelector = &v1.LabelSelector{MatchLabels: map[string]string{"team": "foo"}}
+		Expect(k8sClient.Update(context.Background(), guardrails)).ShouldNot(HaveOccurred())
+		ns := &corev1.Namespace{}
+		Expect(k8sClient.Get(context.Background(), client.ObjectKey{Name: otherNs}, ns)).ShouldNot(HaveOccurred())
+		ns.Labels = map[string]string{"team": "foo"}
+		Expect(k8sClient.Update(context.Background(), ns)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), otherKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should generate resources only for the oldest HTTPProxy using a hostname", func() {
+		nsA := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: nsA},
+		})).ShouldNot(HaveOccurred())
+		nsB := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: nsB},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:          testServiceKey,
+			DefaultIssuerName:   "test-issuer",
+			DefaultIssuerKind:   ClusterIssuerKind,
+			CreateDNSEndpoint:   true,
+			CreateCertificate:   true,
+			HostnameArbitration: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		fqdn := randomString(10) + ".example.com"
+
+		By("creating the first HTTPProxy")
+		keyA := client.ObjectKey{Name: "foo", Namespace: nsA}
+		hpA := newDummyHTTPProxy(keyA)
+		hpA.Spec.VirtualHost.Fqdn = fqdn
+		Expect(k8sClient.Create(context.Background(), hpA)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyA, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		// creationTimestamp has a resolution of seconds
+		time.Sleep(1100 * time.Millisecond)
+
+		By("creating the second HTTPProxy with the same FQDN")
+		keyB := client.ObjectKey{Name: "foo", Namespace: nsB}
+		hpB := newDummyHTTPProxy(keyB)
+		hpB.Spec.VirtualHost.Fqdn = fqdn
+		Expect(k8sClient.Create(context.Background(), hpB)).ShouldNot(HaveOccurred())
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(nsB))).Should(Succeed())
+			g.Expect(events.Items).Should(ContainElement(HaveField("Reason", "HostnameConflict")))
+		}, 5*time.Second).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), keyB, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), keyB, certificate())).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), keyA, dnsEndpoint())).Should(Succeed())
+
+		By("deleting the first HTTPProxy")
+		Expect(k8sClient.Delete(context.Background(), hpA)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyB, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyB, certificate())
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should not let HTTPProxies losing hostname conflicts win other hostnames", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:          testServiceKey,
+			DefaultIssuerName:   "test-issuer",
+			DefaultIssuerKind:   ClusterIssuerKind,
+			CreateDNSEndpoint:   true,
+			CreateCertificate:   true,
+			HostnameArbitration: true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		x := randomString(10) + ".example.com"
+		y := randomString(10) + ".example.com"
+
+		By("creating HTTPProxy A using x")
+		keyA := client.ObjectKey{Name: "a", Namespace: ns}
+		hpA := newDummyHTTPProxy(keyA)
+		hpA.Spec.VirtualHost.Fqdn = x
+		hpA.Spec.VirtualHost.TLS.SecretName = "a-tls"
+		Expect(k8sClient.Create(context.Background(), hpA)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyA, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		// creationTimestamp has a resolution of seconds
+		time.Sleep(1100 * time.Millisecond)
+
+		By("creating HTTPProxy B using x and y")
+		keyB := client.ObjectKey{Name: "b", Namespace: ns}
+		hpB := newDummyHTTPProxy(keyB)
+		hpB.Spec.VirtualHost.Fqdn = x
+		hpB.Spec.VirtualHost.TLS.SecretName = "b-tls"
+		hpB.Annotations[additionalHostnamesAnnotation] = y
+		Expect(k8sClient.Create(context.Background(), hpB)).ShouldNot(HaveOccurred())
+
+		time.Sleep(1100 * time.Millisecond)
+
+		By("creating HTTPProxy C using y")
+		keyC := client.ObjectKey{Name: "c", Namespace: ns}
+		hpC := newDummyHTTPProxy(keyC)
+		hpC.Spec.VirtualHost.Fqdn = y
+		hpC.Spec.VirtualHost.TLS.SecretName = "c-tls"
+		Expect(k8sClient.Create(context.Background(), hpC)).ShouldNot(HaveOccurred())
+
+		By("confirming that C wins y because B loses x")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyC, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyC, certificate())
+		}, 5*time.Second).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), keyB, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		By("deleting A to let B win x and y")
+		Expect(k8sClient.Delete(context.Background(), hpA)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyB, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyC, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), keyC, certificate())
+		}, 5*time.Second).ShouldNot(Succeed())
+	})
+
+	It("should create DNSEndpoints for the public and private views with split-horizon DNS", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			DNSTargetSource:   DNSTargetSourceHTTPProxyStatus,
+			CreateDNSEndpoint: true,
+			SplitHorizon:      true,
+			DNSViewLabel:      "example.com/view",
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy with public and private load balancer addresses")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		privateKey := client.ObjectKey{Name: "foo" + privateDNSEndpointSuffix, Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+		hp.Status.CurrentStatus = "valid"
+		hp.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.1"}, {IP: "10.0.0.4"}}
+		Expect(k8sClient.Status().Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		By("getting DNSEndpoints for both views")
+		for _, tc := range []struct {
+			key    client.ObjectKey
+			view   string
+			target string
+		}{
+			{hpKey, dnsViewPublic, "203.0.113.1"},
+			{privateKey, dnsViewPrivate, "10.0.0.4"},
+		} {
+			de := dnsEndpoint()
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), tc.key, de)
+			}, 5*time.Second).Should(Succeed())
+			Expect(de.GetLabels()).Should(HaveKeyWithValue("example.com/view", tc.view))
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+			Expect(endPoint["targets"]).Should(Equal([]interface{}{tc.target}))
+		}
+
+		By("publishing the records only in the public view")
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		hp.Annotations[dnsViewAnnotation] = dnsViewPublic
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), privateKey, dnsEndpoint())
+		}, 5*time.Second).ShouldNot(Succeed())
+		Expect(k8sClient.Get(context.Background(), hpKey, dnsEndpoint())).Should(Succeed())
+	})
+
+	It("should not take over DNSEndpoints controlled by other HTTPProxies", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			DNSTargetSource:   DNSTargetSourceHTTPProxyStatus,
+			CreateDNSEndpoint: true,
+			SplitHorizon:      true,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy whose name ends with the suffix of private DNSEndpoints")
+		otherKey := client.ObjectKey{Name: "foo" + privateDNSEndpointSuffix, Namespace: ns}
+		other := newDummyHTTPProxy(otherKey)
+		other.Spec.VirtualHost.Fqdn = "other.example.com"
+		Expect(k8sClient.Create(context.Background(), other)).ShouldNot(HaveOccurred())
+		other.Status.CurrentStatus = "valid"
+		other.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.2"}}
+		Expect(k8sClient.Status().Update(context.Background(), other)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), otherKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("creating HTTPProxy whose private DNSEndpoint has the same name")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+		hp.Status.CurrentStatus = "valid"
+		hp.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "203.0.113.1"}, {IP: "10.0.0.4"}}
+		Expect(k8sClient.Status().Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming the DNSEndpoint of the other HTTPProxy is not updated")
+		Expect(k8sClient.Get(context.Background(), otherKey, other)).ShouldNot(HaveOccurred())
+		Consistently(func(g Gomega) {
+			de := dnsEndpoint()
+			g.Expect(k8sClient.Get(context.Background(), otherKey, de)).Should(Succeed())
+			g.Expect(v1.GetControllerOf(de).UID).Should(Equal(other.UID))
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+			g.Expect(endPoint["dnsName"]).Should(Equal("other.example.com"))
+			g.Expect(endPoint["targets"]).Should(Equal([]interface{}{"203.0.113.2"}))
+		}, 3*time.Second).Should(Succeed())
+	})
+
+	It("should publish only the addresses of the allowed IP families", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:        testServiceKey,
+			CreateDNSEndpoint: true,
+			IPFamilies:        IPFamiliesDual,
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy requesting IPv6 records for an IPv4-only load balancer")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[ipFamiliesAnnotation] = IPFamiliesIPv6
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(ns))).Should(Succeed())
+			g.Expect(events.Items).Should(ContainElement(HaveField("Reason", "NoEligibleAddress")))
+		}, 5*time.Second).Should(Succeed())
+		Expect(k8sClient.Get(context.Background(), hpKey, dnsEndpoint())).ShouldNot(Succeed())
+
+		By("allowing IPv4 records")
+		Expect(k8sClient.Get(context.Background(), hpKey, hp)).ShouldNot(HaveOccurred())
+		hp.Annotations[ipFamiliesAnnotation] = IPFamiliesIPv4
+		Expect(k8sClient.Update(context.Background(), hp)).ShouldNot(HaveOccurred())
+		de := dnsEndpoint()
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, de)
+		}, 5*time.Second).Should(Succeed())
+		deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+		endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+		Expect(endPoint["recordType"]).Should(Equal("A"))
+		Expect(endPoint["targets"]).Should(Equal([]interface{}{dummyLoadBalancerIP}))
+	})
+
+	It("should render delegation targets with templates", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		defaultTemplate, err := ParseDelegationTargetTemplate(`{{replace .FQDN "." "-"}}.{{.Domain}}`)
+		Expect(err).ShouldNot(HaveOccurred())
+		vendorTemplate, err := ParseDelegationTargetTemplate(`{{sha256 .FQDN | trunc 32}}.{{.Domain}}`)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			DefaultIssuerName:       "test-issuer",
+			DefaultIssuerKind:       IssuerKind,
+			CreateDNSEndpoint:       true,
+			CreateCertificate:       true,
+			DefaultDelegatedDomain:  "acme.example.net",
+			AllowCustomDelegations:  true,
+			AllowedDelegatedDomains: []string{"vendor.example.net"},
+			DelegationTargets: &DelegationTargetTemplates{
+				Default: defaultTemplate,
+				Domains: map[string]*template.Template{"vendor.example.net": vendorTemplate},
+			},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxies")
+		defaultKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(defaultKey))).ShouldNot(HaveOccurred())
+		vendorKey := client.ObjectKey{Name: "bar", Namespace: ns}
+		vendor := newDummyHTTPProxy(vendorKey)
+		vendor.Annotations[delegatedDomainAnnotation] = "vendor.example.net"
+		Expect(k8sClient.Create(context.Background(), vendor)).ShouldNot(HaveOccurred())
+
+		By("getting delegation DNSEndpoints")
+		sum := sha256.Sum256([]byte(dnsName))
+		for _, tc := range []struct {
+			key    client.ObjectKey
+			target string
+		}{
+			{defaultKey, strings.ReplaceAll(dnsName, ".", "-") + ".acme.example.net"},
+			{vendorKey, hex.EncodeToString(sum[:])[:32] + ".vendor.example.net"},
+		} {
+			de := dnsEndpoint()
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: tc.key.Name + "-delegation"}, de)
+			}, 5*time.Second).Should(Succeed())
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+			Expect(endPoint["targets"]).Should(Equal([]interface{}{tc.target}))
+		}
+	})
+
+	It("should match custom delegated domains with patterns", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:              testServiceKey,
+			DefaultIssuerName:       "test-issuer",
+			DefaultIssuerKind:       IssuerKind,
+			CreateDNSEndpoint:       true,
+			CreateCertificate:       true,
+			DefaultDelegatedDomain:  testDelegationName,
+			AllowCustomDelegations:  true,
+			AllowedDelegatedDomains: []string{"*.tenants." + testDelegationName},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxies with custom delegated domains")
+		allowedKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		allowed := newDummyHTTPProxy(allowedKey)
+		allowed.Annotations[delegatedDomainAnnotation] = "team-a.tenants." + testDelegationName
+		Expect(k8sClient.Create(context.Background(), allowed)).ShouldNot(HaveOccurred())
+		rejectedKey := client.ObjectKey{Name: "bar", Namespace: ns}
+		rejected := newDummyHTTPProxy(rejectedKey)
+		rejected.Annotations[delegatedDomainAnnotation] = "other.example.net"
+		Expect(k8sClient.Create(context.Background(), rejected)).ShouldNot(HaveOccurred())
+
+		By("getting delegation DNSEndpoints")
+		for _, tc := range []struct {
+			key    client.ObjectKey
+			domain string
+		}{
+			{allowedKey, "team-a.tenants." + testDelegationName},
+			{rejectedKey, testDelegationName},
+		} {
+			de := dnsEndpoint()
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: tc.key.Name + "-delegation"}, de)
+			}, 5*time.Second).Should(Succeed())
+			deSpec := de.UnstructuredContent()["spec"].(map[string]interface{})
+			endPoint := deSpec["endpoints"].([]interface{})[0].(map[string]interface{})
+			Expect(endPoint["targets"]).Should(Equal([]interface{}{"_acme-challenge." + dnsName + "." + tc.domain}))
+		}
+
+		By("confirming that the rejected delegated domain is reported")
+		Eventually(func(g Gomega) {
+			var events corev1.EventList
+			g.Expect(k8sClient.List(context.Background(), &events, client.InNamespace(ns))).Should(Succeed())
+			g.Expect(events.Items).Should(ContainElement(And(
+				HaveField("Reason", "DelegatedDomainNotAllowed"),
+				HaveField("InvolvedObject.Name", rejectedKey.Name),
+			)))
+		}, 5*time.Second).Should(Succeed())
+	})
+
+	It("should create delegation DNSEndpoint only for DNS-01 challenges", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating ACME Issuer solving DNS-01 challenges only for labeled Certificates")
+		issuer := &unstructured.Unstructured{}
+		issuer.SetGroupVersionKind(certManagerGroupVersion.WithKind(IssuerKind))
+		issuer.SetName("acme-issuer")
+		issuer.SetNamespace(ns)
+		issuer.UnstructuredContent()["spec"] = map[string]interface{}{
+			"acme": map[string]interface{}{
+				"server":              "https://acme.example.com/directory",
+				"privateKeySecretRef": map[string]interface{}{"name": "acme-account-key"},
+				"solvers": []interface{}{
+					map[string]interface{}{
+						"http01": map[string]interface{}{"ingress": map[string]interface{}{}},
+					},
+					map[string]interface{}{
+						"selector": map[string]interface{}{
+							"matchLabels": map[string]interface{}{"solver": "dns01"},
+						},
+						"dns01": map[string]interface{}{
+							"webhook": map[string]interface{}{"groupName": testDelegationName, "solverName": "test"},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), issuer)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "acme-issuer",
+			DefaultIssuerKind:      IssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+			PropagatedLabels:       []string{"solver"},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxies")
+		http01Key := client.ObjectKey{Name: "foo", Namespace: ns}
+		Expect(k8sClient.Create(context.Background(), newDummyHTTPProxy(http01Key))).ShouldNot(HaveOccurred())
+		dns01Key := client.ObjectKey{Name: "bar", Namespace: ns}
+		dns01 := newDummyHTTPProxy(dns01Key)
+		dns01.Labels = map[string]string{"solver": "dns01"}
+		dns01.Spec.VirtualHost.TLS.SecretName = "bar-tls"
+		Expect(k8sClient.Create(context.Background(), dns01)).ShouldNot(HaveOccurred())
+
+		By("getting delegation DNSEndpoint for DNS-01 challenges")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: dns01Key.Name + "-delegation"}, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that delegation DNSEndpoint is not created for HTTP-01 challenges")
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), http01Key, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: http01Key.Name + "-delegation"}, dnsEndpoint())
+		}, 2*time.Second).ShouldNot(Succeed())
+
+		By("switching the issuer to DNS-01 challenges for all Certificates")
+		Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(issuer), issuer)).ShouldNot(HaveOccurred())
+		Expect(unstructured.SetNestedSlice(issuer.Object, []interface{}{
+			map[string]interface{}{
+				"dns01": map[string]interface{}{
+					"webhook": map[string]interface{}{"groupName": testDelegationName, "solverName": "test"},
+				},
+			},
+		}, "spec", "acme", "solvers")).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Update(context.Background(), issuer)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: http01Key.Name + "-delegation"}, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+	})
+	It("should not create delegation DNSEndpoint for issuers refused by issuer policy", func() {
+		ns := testNamespacePrefix + randomString(10)
+		Expect(k8sClient.Create(context.Background(), &corev1.Namespace{
+			ObjectMeta: ctrl.ObjectMeta{Name: ns},
+		})).ShouldNot(HaveOccurred())
+
+		By("creating ACME Issuer solving DNS-01 challenges")
+		issuer := &unstructured.Unstructured{}
+		issuer.SetGroupVersionKind(certManagerGroupVersion.WithKind(IssuerKind))
+		issuer.SetName("dns01-issuer")
+		issuer.SetNamespace(ns)
+		issuer.UnstructuredContent()["spec"] = map[string]interface{}{
+			"acme": map[string]interface{}{
+				"server":              "https://acme.example.com/directory",
+				"privateKeySecretRef": map[string]interface{}{"name": "acme-account-key"},
+				"solvers": []interface{}{
+					map[string]interface{}{
+						"dns01": map[string]interface{}{
+							"webhook": map[string]interface{}{"groupName": testDelegationName, "solverName": "test"},
+						},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), issuer)).ShouldNot(HaveOccurred())
+
+		scm, mgr := setupManager()
+
+		Expect(SetupReconciler(mgr, scm, ReconcilerOptions{
+			ServiceKey:             testServiceKey,
+			DefaultIssuerName:      "test-issuer",
+			DefaultIssuerKind:      ClusterIssuerKind,
+			DefaultDelegatedDomain: testDelegationName,
+			CreateDNSEndpoint:      true,
+			CreateCertificate:      true,
+			IssuerPolicy:           &IssuerPolicy{DisallowedAction: IssuerPolicyActionRefuse},
+		})).ShouldNot(HaveOccurred())
+
+		stopMgr := startTestManager(mgr)
+		defer stopMgr()
+
+		By("creating HTTPProxy requesting the issuer")
+		hpKey := client.ObjectKey{Name: "foo", Namespace: ns}
+		hp := newDummyHTTPProxy(hpKey)
+		hp.Annotations[issuerNameAnnotation] = "dns01-issuer"
+		Expect(k8sClient.Create(context.Background(), hp)).ShouldNot(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(context.Background(), hpKey, dnsEndpoint())
+		}, 5*time.Second).Should(Succeed())
+
+		By("confirming that neither Certificate nor delegation DNSEndpoint is created")
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(context.Background(), hpKey, certificate())).ShouldNot(Succeed())
+			g.Expect(k8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns, Name: hpKey.Name + "-delegation"}, dnsEndpoint())).ShouldNot(Succeed())
+		}, 2*time.Second).Should(Succeed())
+	})
+}
+
+func newDummyHTTPProxy(hpKey client.ObjectKey) *projectcontourv1.HTTPProxy {
+	return &projectcontourv1.HTTPProxy{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: hpKey.Namespace,
+			Name:      hpKey.Name,
+			Annotations: map[string]string{
+				testACMETLSAnnotation: "true",
+			},
+		},
+		Spec: projectcontourv1.HTTPProxySpec{
+			VirtualHost: &projectcontourv1.VirtualHost{
+				Fqdn: dnsName,
+				TLS:  &projectcontourv1.TLS{SecretName: testSecretName},
+			},
+			Routes: []projectcontourv1.Route{},
+		},
+	}
+}
+
+func TestIsClassNameMatched(t *testing.T) {
+	tests := []struct {
+		name             string
+		ingressClassName string
+		hp               *projectcontourv1.HTTPProxy
+		want             bool
+	}{
+		{
+			name:             "Annotation is not set",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{},
+				},
+			},
+			want: false,
+		},
+		{
+			name:             "Spec is not set",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{},
+				},
+				Spec: projectcontourv1.HTTPProxySpec{},
+			},
+			want: false,
+		},
+		{
+			name:             "Both annotation are set",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						ingressClassNameAnnotation:        "class-name",
+						contourIngressClassNameAnnotation: "class-name",
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name:             "Both annotation and spec are set",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						ingressClassNameAnnotation:        "class-name",
+						contourIngressClassNameAnnotation: "class-name",
+					},
+				},
+				Spec: projectcontourv1.HTTPProxySpec{
+					IngressClassName: "class-name",
+				},
+			},
+			want: true,
+		},
+		{
+			name:             "Both annotation are set but not matched",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						ingressClassNameAnnotation:        "class-name",
+						contourIngressClassNameAnnotation: "wrong",
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name:             "Both annotation and spec are set but not matched",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						ingressClassNameAnnotation:        "class-name",
+						contourIngressClassNameAnnotation: "wrong",
+					},
+				},
+				Spec: projectcontourv1.HTTPProxySpec{
+					IngressClassName: "class-name",
+				},
+			},
+			want: false,
+		},
+		{
+			name:             fmt.Sprintf("Annotation %s is set", ingressClassNameAnnotation),
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						ingressClassNameAnnotation: "class-name",
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name:             fmt.Sprintf("Annotation %s is set but not matched", ingressClassNameAnnotation),
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						ingressClassNameAnnotation: "wrong",
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name:             fmt.Sprintf("Annotation %s is set", contourIngressClassNameAnnotation),
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						contourIngressClassNameAnnotation: "class-name",
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name:             fmt.Sprintf("Annotation %s is set but not matched", contourIngressClassNameAnnotation),
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						contourIngressClassNameAnnotation: "wrong",
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			name:             "Spec is set",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{},
+				},
+				Spec: projectcontourv1.HTTPProxySpec{
+					IngressClassName: "class-name",
+				},
+			},
+			want: true,
+		},
+		{
+			name:             "Spec is set but not matched",
+			ingressClassName: "class-name",
+			hp: &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{},
+				},
+				Spec: projectcontourv1.HTTPProxySpec{
+					IngressClassName: "wrong",
+				},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &HTTPProxyReconciler{
+				IngressClassName: tt.ingressClassName,
+			}
+			if got := r.isClassNameMatched(tt.hp); got != tt.want {
+				t.Errorf("HTTPProxyReconciler.isClassNameMatched() = %v, want %v", got, tt.want)
+			}
+		})
 	}
 }
 
@@ -1399,10 +3457,23 @@ func TestMakeDelegationEndpoint(t *testing.T) {
 			expectDNSName:   "_acme-challenge.example.com",
 			expectTarget:    "_acme-challenge.example.com.delegated.com",
 		},
+		{
+			name:            "Wildcard hostname",
+			hostname:        "*.apps.example.com",
+			delegatedDomain: "delegated.com",
+			expectDNSName:   "_acme-challenge.apps.example.com",
+			expectTarget:    "_acme-challenge.apps.example.com.delegated.com",
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			actuals := makeDelegationEndpoint(tc.hostname, tc.delegatedDomain)
+			hp := &projectcontourv1.HTTPProxy{}
+			var templates *DelegationTargetTemplates
+			target, err := templates.target(hp, tc.hostname, tc.delegatedDomain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actuals := makeDelegationEndpoint(tc.hostname, target, defaultRecordTTL)
 			if len(actuals) != 1 {
 				t.Errorf("HTTPProxyReconciler.makeDelegationEndpoint() = %v, want 1 item", len(actuals))
 			}
@@ -1420,3 +3491,228 @@ func TestMakeDelegationEndpoint(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadBalancerTargets(t *testing.T) {
+	ingresses := []corev1.LoadBalancerIngress{
+		{IP: "10.0.0.1"},
+		{Hostname: "lb.example.com"},
+		{IP: "fd00::1", Hostname: "ignored.example.com"},
+		{IP: "invalid"},
+		{},
+	}
+	ips, hostnames := loadBalancerTargets(ingresses)
+	if len(ips) != 2 || ips[0].String() != "10.0.0.1" || ips[1].String() != "fd00::1" {
+		t.Errorf("loadBalancerTargets() ips = %v, want [10.0.0.1 fd00::1]", ips)
+	}
+	if len(hostnames) != 1 || hostnames[0] != "lb.example.com" {
+		t.Errorf("loadBalancerTargets() hostnames = %v, want [lb.example.com]", hostnames)
+	}
+}
+
+func TestMakeEndpoints(t *testing.T) {
+	tests := []struct {
+		name        string
+		ips         []net.IP
+		lbHostnames []string
+		ipFamilies  string
+		expect      map[string][]string
+	}{
+		{
+			name:   "IPv4 and IPv6 addresses",
+			ips:    []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+			expect: map[string][]string{"A": {"10.0.0.1"}, "AAAA": {"fd00::1"}},
+		},
+		{
+			name:        "Hostname only",
+			lbHostnames: []string{"lb.example.com."},
+			expect:      map[string][]string{"CNAME": {"lb.example.com"}},
+		},
+		{
+			name:        "Multiple hostnames",
+			lbHostnames: []string{"lb1.example.com", "lb2.example.com"},
+			expect:      map[string][]string{"CNAME": {"lb1.example.com"}},
+		},
+		{
+			name:        "IP addresses take precedence over hostnames",
+			ips:         []net.IP{net.ParseIP("10.0.0.1")},
+			lbHostnames: []string{"lb.example.com"},
+			expect:      map[string][]string{"A": {"10.0.0.1"}},
+		},
+		{
+			name:   "No targets",
+			expect: map[string][]string{},
+		},
+		{
+			name:       "IPv4 only",
+			ips:        []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+			ipFamilies: IPFamiliesIPv4,
+			expect:     map[string][]string{"A": {"10.0.0.1"}},
+		},
+		{
+			name:       "IPv6 only",
+			ips:        []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+			ipFamilies: IPFamiliesIPv6,
+			expect:     map[string][]string{"AAAA": {"fd00::1"}},
+		},
+		{
+			name:        "No eligible IP addresses",
+			ips:         []net.IP{net.ParseIP("10.0.0.1")},
+			lbHostnames: []string{"lb.example.com"},
+			ipFamilies:  IPFamiliesIPv6,
+			expect:      map[string][]string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actuals := makeEndpoints(dnsName, tc.ips, tc.lbHostnames, dnsRecordPolicy{ttl: defaultRecordTTL, ipFamilies: tc.ipFamilies})
+			if len(actuals) != len(tc.expect) {
+				t.Fatalf("makeEndpoints() = %v, want %d items", actuals, len(tc.expect))
+			}
+			for _, actual := range actuals {
+				if actual["dnsName"] != dnsName {
+					t.Errorf("makeEndpoints() dnsName = %v, want %v", actual["dnsName"], dnsName)
+				}
+				recordType := actual["recordType"].(string)
+				expectTargets, ok := tc.expect[recordType]
+				if !ok {
+					t.Errorf("makeEndpoints() unexpected recordType %v", recordType)
+					continue
+				}
+				if !slices.Equal(actual["targets"].([]string), expectTargets) {
+					t.Errorf("makeEndpoints() %s targets = %v, want %v", recordType, actual["targets"], expectTargets)
+				}
+			}
+		})
+	}
+}
+
+func TestServiceKeyFor(t *testing.T) {
+	internalKey := client.ObjectKey{Namespace: "ingress", Name: "internal"}
+	r := &HTTPProxyReconciler{
+		ServiceKey: testServiceKey,
+		ServiceKeys: map[string]client.ObjectKey{
+			"internal": internalKey,
+		},
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        client.ObjectKey
+		wantErr     bool
+	}{
+		{
+			name: "Annotation is not set",
+			want: testServiceKey,
+		},
+		{
+			name:        "Annotation selects an allowed service",
+			annotations: map[string]string{serviceNameAnnotation: "internal"},
+			want:        internalKey,
+		},
+		{
+			name:        "Annotation selects an unknown service",
+			annotations: map[string]string{serviceNameAnnotation: "external"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: tt.annotations,
+				},
+			}
+			got, err := r.serviceKeyFor(hp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPProxyReconciler.serviceKeyFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("HTTPProxyReconciler.serviceKeyFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngressClassServices(t *testing.T) {
+	internalKey := client.ObjectKey{Namespace: "internal", Name: "envoy"}
+	externalKey := client.ObjectKey{Namespace: "external", Name: "envoy"}
+	r := &HTTPProxyReconciler{
+		ServiceKeys: map[string]client.ObjectKey{
+			"external": externalKey,
+		},
+		IngressClassServiceKeys: map[string]client.ObjectKey{
+			"internal": internalKey,
+			"external": externalKey,
+		},
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		spec        projectcontourv1.HTTPProxySpec
+		wantMatched bool
+		want        client.ObjectKey
+		wantErr     bool
+	}{
+		{
+			name:        "Class given by annotation",
+			annotations: map[string]string{contourIngressClassNameAnnotation: "internal"},
+			wantMatched: true,
+			want:        internalKey,
+		},
+		{
+			name:        "Class given by spec",
+			spec:        projectcontourv1.HTTPProxySpec{IngressClassName: "external"},
+			wantMatched: true,
+			want:        externalKey,
+		},
+		{
+			name: "Service annotation takes precedence over class",
+			annotations: map[string]string{
+				contourIngressClassNameAnnotation: "internal",
+				serviceNameAnnotation:             "external",
+			},
+			wantMatched: true,
+			want:        externalKey,
+		},
+		{
+			name:        "Unknown class",
+			annotations: map[string]string{ingressClassNameAnnotation: "unknown"},
+			wantMatched: false,
+			wantErr:     true,
+		},
+		{
+			name: "Inconsistent classes",
+			annotations: map[string]string{
+				ingressClassNameAnnotation:        "internal",
+				contourIngressClassNameAnnotation: "external",
+			},
+			wantMatched: false,
+			wantErr:     true,
+		},
+		{
+			name:        "No class",
+			wantMatched: false,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: tt.annotations,
+				},
+				Spec: tt.spec,
+			}
+			if got := r.isClassNameMatched(hp); got != tt.wantMatched {
+				t.Errorf("HTTPProxyReconciler.isClassNameMatched() = %v, want %v", got, tt.wantMatched)
+			}
+			got, err := r.serviceKeyFor(hp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPProxyReconciler.serviceKeyFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("HTTPProxyReconciler.serviceKeyFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// certManagerGroup is the API group of the issuers built into cert-manager
+	certManagerGroup = "cert-manager.io"
+
+	issuerKindAnnotation  = "cert-manager.io/issuer-kind"
+	issuerGroupAnnotation = "cert-manager.io/issuer-group"
+)
+
+// errIssuerNotReady is returned when the issuer of a Certificate does not exist or is not ready
+var errIssuerNotReady = errors.New("issuer is not ready")
+
+// issuerRef refers to the issuer of a Certificate.
+// An empty group means the issuers built into cert-manager.
+type issuerRef struct {
+	name  string
+	kind  string
+	group string
+}
+
+func (ref issuerRef) String() string {
+	if ref.group == "" {
+		return ref.kind + "/" + ref.name
+	}
+	return ref.kind + "." + ref.group + "/" + ref.name
+}
+
+// isCertManager returns true if the issuer is built into cert-manager.
+func (ref issuerRef) isCertManager() bool {
+	return ref.group == "" || ref.group == certManagerGroup
+}
+
+// toMap returns the issuerRef field of the Certificate spec
+func (ref issuerRef) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"kind": ref.kind,
+		"name": ref.name,
+	}
+	if ref.group != "" {
+		m["group"] = ref.group
+	}
+	return m
+}
+
+// issuerOf returns the issuer for the HTTPProxy.
+// As with ingress-shim of cert-manager, the issuer-kind and issuer-group annotations override the kind and the group
+// of the issuer given by the issuer or cluster-issuer annotation.
+// It returns an error if the kind of the issuer is not allowed.
+func (r *HTTPProxyReconciler) issuerOf(hp *projectcontourv1.HTTPProxy) (issuerRef, error) {
+	ref := issuerRef{
+		name:  r.DefaultIssuerName,
+		kind:  r.DefaultIssuerKind,
+		group: r.DefaultIssuerGroup,
+	}
+
+	annotated := false
+	if name, ok := hp.Annotations[issuerNameAnnotation]; ok {
+		ref = issuerRef{name: name, kind: IssuerKind}
+		annotated = true
+	}
+	if name, ok := hp.Annotations[clusterIssuerNameAnnotation]; ok {
+		ref = issuerRef{name: name, kind: ClusterIssuerKind}
+		annotated = true
+	}
+	if annotated {
+		if kind, ok := hp.Annotations[issuerKindAnnotation]; ok {
+			ref.kind = kind
+		}
+		if group, ok := hp.Annotations[issuerGroupAnnotation]; ok {
+			ref.group = group
+		}
+	}
+
+	if !r.isIssuerKindAllowed(ref.kind, ref.group) {
+		return issuerRef{}, fmt.Errorf("issuer kind %s is not allowed", schema.GroupKind{Group: ref.group, Kind: ref.kind})
+	}
+	return ref, nil
+}
+
+// isIssuerKindAllowed returns true if Certificates may refer to issuers of the kind in the group.
+// The issuers built into cert-manager are always allowed, and external issuers must be listed in AllowedIssuerKinds.
+func (r *HTTPProxyReconciler) isIssuerKindAllowed(kind, group string) bool {
+	return IsIssuerKindAllowed(kind, group, r.AllowedIssuerKinds)
+}
+
+// IsIssuerKindAllowed returns true if the kind in the group is a cert-manager issuer or listed in allowed.
+func IsIssuerKindAllowed(kind, group string, allowed []schema.GroupKind) bool {
+	if group == "" || group == certManagerGroup {
+		return kind == IssuerKind || kind == ClusterIssuerKind
+	}
+	return slices.Contains(allowed, schema.GroupKind{Group: group, Kind: kind})
+}
+
+// checkIssuer returns an error wrapping errIssuerNotReady if the issuer does not exist or does not report Ready.
+// namespace is the namespace of the Certificate, where a namespaced issuer must exist.
+// External issuers are not verified because they are neither watched nor readable with the permissions of contour-plus.
+func (r *HTTPProxyReconciler) checkIssuer(ctx context.Context, ref issuerRef, namespace string) error {
+	if !r.VerifyIssuer || !ref.isCertManager() {
+		return nil
+	}
+
+	obj, err := r.getIssuer(ctx, ref, namespace)
+	if err != nil {
+		return err
+	}
+
+	if !isReady(obj) {
+		return fmt.Errorf("%w: %s is not ready", errIssuerNotReady, ref)
+	}
+	return nil
+}
+
+// getIssuer returns the cert-manager issuer of a Certificate in the namespace.
+// It returns an error wrapping errIssuerNotReady if the issuer or its kind does not exist.
+// External issuers must not be given because reading them through the cache would block on an informer never synced.
+func (r *HTTPProxyReconciler) getIssuer(ctx context.Context, ref issuerRef, namespace string) (*unstructured.Unstructured, error) {
+	if !ref.isCertManager() {
+		return nil, fmt.Errorf("%s is not an issuer of cert-manager", ref)
+	}
+	mapping, err := r.RESTMapper().RESTMapping(schema.GroupKind{Group: certManagerGroup, Kind: ref.kind})
+	if meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("%w: %s is not installed", errIssuerNotReady, ref.kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(mapping.GroupVersionKind)
+	key := client.ObjectKey{Name: ref.name}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		key.Namespace = namespace
+	}
+	err = r.Get(ctx, key, obj)
+	if k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s is not found", errIssuerNotReady, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// isReady returns true if the object has the Ready condition whose status is True, as cert-manager issuers do.
+func isReady(obj *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == "Ready" {
+			return cond["status"] == "True"
+		}
+	}
+	return false
+}
+
+// issuerRequests returns the requests for the HTTPProxies whose Certificates are issued by the issuer.
+func (r *HTTPProxyReconciler) issuerRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	var hpList projectcontourv1.HTTPProxyList
+	err := r.List(ctx, &hpList)
+	if err != nil {
+		r.Log.Error(err, "listing HTTPProxy failed")
+		return nil
+	}
+
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	var requests []reconcile.Request
+	for i := range hpList.Items {
+		hp := &hpList.Items[i]
+		secretKey, ok := certificateSecretKey(hp)
+		if !ok {
+			continue
+		}
+		defaulted, err := r.withNamespaceDefaults(ctx, hp)
+		if err != nil {
+			r.Log.Error(err, "getting Namespace failed", "namespace", hp.Namespace)
+			continue
+		}
+		nr, err := r.forNamespace(ctx, secretKey.Namespace)
+		if err != nil {
+			r.Log.Error(err, "getting ContourPlusPolicy failed", "namespace", secretKey.Namespace)
+			continue
+		}
+		issuer, err := nr.issuerOf(defaulted)
+		if err != nil || issuer.name != obj.GetName() || issuer.kind != kind {
+			continue
+		}
+		if !issuer.isCertManager() {
+			continue
+		}
+		if obj.GetNamespace() != "" && obj.GetNamespace() != secretKey.Namespace {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: hp.Namespace,
+			Name:      hp.Name,
+		}})
+	}
+	return requests
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Constants for actions on HTTPProxies requesting issuers not allowed by IssuerPolicy
+const (
+	IssuerPolicyActionFallback = "fallback"
+	IssuerPolicyActionRefuse   = "refuse"
+)
+
+// IssuerPolicy restricts the issuers that HTTPProxies in each namespace may use.
+// The default issuer given by ReconcilerOptions is always allowed.
+type IssuerPolicy struct {
+	// Rules lists the issuers allowed for the namespaces matched by each rule.
+	Rules []IssuerPolicyRule `json:"rules"`
+
+	// DisallowedAction is either "fallback" to use the default issuer instead, or "refuse" to generate no Certificate.
+	// Defaults to "refuse".
+	DisallowedAction string `json:"disallowedAction,omitempty"`
+}
+
+// IssuerPolicyRule allows the issuers for the namespaces listed in Namespaces or matched by NamespaceSelector.
+type IssuerPolicyRule struct {
+	Namespaces        []string              `json:"namespaces,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Issuers lists the allowed issuers. A name of "*" allows all issuers of the kind.
+	Issuers []IssuerPolicyIssuer `json:"issuers"`
+}
+
+// IssuerPolicyIssuer identifies issuers in IssuerPolicyRule
+type IssuerPolicyIssuer struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Group string `json:"group,omitempty"`
+}
+
+// LoadIssuerPolicy reads IssuerPolicy from a YAML file.
+func LoadIssuerPolicy(path string) (*IssuerPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &IssuerPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid issuer policy in %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func (p *IssuerPolicy) validate() error {
+	switch p.DisallowedAction {
+	case "":
+		p.DisallowedAction = IssuerPolicyActionRefuse
+	case IssuerPolicyActionFallback, IssuerPolicyActionRefuse:
+	default:
+		return fmt.Errorf("disallowedAction must be %s or %s: %s", IssuerPolicyActionFallback, IssuerPolicyActionRefuse, p.DisallowedAction)
+	}
+
+	for i, rule := range p.Rules {
+		if len(rule.Namespaces) == 0 && rule.NamespaceSelector == nil {
+			return fmt.Errorf("rules[%d] must have namespaces or namespaceSelector", i)
+		}
+		if rule.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(rule.NamespaceSelector); err != nil {
+				return fmt.Errorf("rules[%d] has invalid namespaceSelector: %w", i, err)
+			}
+		}
+		for j, issuer := range rule.Issuers {
+			if issuer.Name == "" || issuer.Kind == "" {
+				return fmt.Errorf("rules[%d].issuers[%d] must have name and kind", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesNamespace returns true if the rule applies to the namespace.
+func (rule *IssuerPolicyRule) matchesNamespace(ns *corev1.Namespace) bool {
+	return namespaceMatches(ns, rule.Namespaces, rule.NamespaceSelector)
+}
+
+// namespaceMatches returns true if the namespace is listed in names or matched by selector.
+func namespaceMatches(ns *corev1.Namespace, names []string, selector *metav1.LabelSelector) bool {
+	if slices.Contains(names, ns.Name) {
+		return true
+	}
+	if selector == nil {
+		return false
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(ns.Labels))
+}
+
+// allows returns true if the rule allows the issuer.
+func (rule *IssuerPolicyRule) allows(ref issuerRef) bool {
+	for _, issuer := range rule.Issuers {
+		if issuer.Kind != ref.kind || normalizeIssuerGroup(issuer.Group) != normalizeIssuerGroup(ref.group) {
+			continue
+		}
+		if issuer.Name == "*" || issuer.Name == ref.name {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if a rule matching the namespace allows the issuer.
+func (p *IssuerPolicy) allows(ns *corev1.Namespace, ref issuerRef) bool {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.matchesNamespace(ns) && rule.allows(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeIssuerGroup(group string) string {
+	if group == "" {
+		return certManagerGroup
+	}
+	return group
+}
+
+// certificateIssuer decides the issuer of the Certificate whose settings are taken from the HTTPProxy.
+// It returns the issuer requested by the HTTPProxy, and the issuer to be used after IssuerPolicy is applied.
+// The requested issuer is returned also when IssuerPolicy refuses it.
+func (r *HTTPProxyReconciler) certificateIssuer(ctx context.Context, hp *projectcontourv1.HTTPProxy) (requested issuerRef, issuer issuerRef, err error) {
+	requested, err = r.issuerOf(hp)
+	if err != nil {
+		return issuerRef{}, issuerRef{}, err
+	}
+	issuer, err = r.applyIssuerPolicy(ctx, hp, requested)
+	return requested, issuer, err
+}
+
+// applyIssuerPolicy checks the issuer requested by the HTTPProxy against IssuerPolicy,
+// and returns the issuer to be used.
+// Only the default issuer given by ReconcilerOptions is exempt; a default issuer of ContourPlusPolicy
+// must be allowed by IssuerPolicy as well.
+// If the issuer is not allowed, it returns either the default issuer or an error wrapping errInvalidCertificateSettings
+// and errIssuerNotAllowed according to the policy.
+func (r *HTTPProxyReconciler) applyIssuerPolicy(ctx context.Context, hp *projectcontourv1.HTTPProxy, ref issuerRef) (issuerRef, error) {
+	if r.IssuerPolicy == nil {
+		return ref, nil
+	}
+
+	base := r
+	if r.base != nil {
+		base = r.base
+	}
+	exemptRef := issuerRef{name: base.DefaultIssuerName, kind: base.DefaultIssuerKind, group: base.DefaultIssuerGroup}
+	if ref == exemptRef {
+		return ref, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: hp.Namespace}, ns); err != nil {
+		return issuerRef{}, err
+	}
+	if r.IssuerPolicy.allows(ns, ref) {
+		return ref, nil
+	}
+
+	defaultRef := issuerRef{name: r.DefaultIssuerName, kind: r.DefaultIssuerKind, group: r.DefaultIssuerGroup}
+	if defaultRef != exemptRef && !r.IssuerPolicy.allows(ns, defaultRef) {
+		defaultRef = exemptRef
+	}
+	if r.IssuerPolicy.DisallowedAction == IssuerPolicyActionFallback && defaultRef.name != "" {
+		return defaultRef, nil
+	}
+	return issuerRef{}, fmt.Errorf("%w: %w", errInvalidCertificateSettings, errIssuerNotAllowed)
+}
+
+// errIssuerNotAllowed is returned when IssuerPolicy does not allow the issuer
+var errIssuerNotAllowed = errors.New("issuer is not allowed")
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadIssuerPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantErr    bool
+		wantAction string
+	}{
+		{
+			name: "Valid policy",
+			content: `
+rules:
+- namespaces: [team-a]
+  namespaceSelector:
+    matchLabels:
+      tier: premium
+  issuers:
+  - kind: ClusterIssuer
+    name: commercial-ca
+  - kind: Issuer
+    name: "*"
+disallowedAction: fallback
+`,
+			wantAction: IssuerPolicyActionFallback,
+		},
+		{
+			name: "Default action",
+			content: `
+rules:
+- namespaces: [team-a]
+  issuers:
+  - kind: ClusterIssuer
+    name: commercial-ca
+`,
+			wantAction: IssuerPolicyActionRefuse,
+		},
+		{
+			name: "Unknown action",
+			content: `
+rules: []
+disallowedAction: ignore
+`,
+			wantErr: true,
+		},
+		{
+			name: "Rule without namespaces",
+			content: `
+rules:
+- issuers:
+  - kind: ClusterIssuer
+    name: commercial-ca
+`,
+			wantErr: true,
+		},
+		{
+			name: "Issuer without kind",
+			content: `
+rules:
+- namespaces: [team-a]
+  issuers:
+  - name: commercial-ca
+`,
+			wantErr: true,
+		},
+		{
+			name: "Unknown field",
+			content: `
+rule: []
+`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := LoadIssuerPolicy(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if policy.DisallowedAction != tc.wantAction {
+				t.Errorf("DisallowedAction = %s, want %s", policy.DisallowedAction, tc.wantAction)
+			}
+		})
+	}
+}
+
+func TestIssuerPolicyRule(t *testing.T) {
+	rule := &IssuerPolicyRule{
+		Namespaces: []string{"team-a"},
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"tier": "premium"},
+		},
+		Issuers: []IssuerPolicyIssuer{
+			{Kind: ClusterIssuerKind, Name: "commercial-ca"},
+			{Kind: IssuerKind, Name: "*", Group: certManagerGroup},
+		},
+	}
+
+	namespaces := []struct {
+		ns   *corev1.Namespace
+		want bool
+	}{
+		{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, true},
+		{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"tier": "premium"}}}, true},
+		{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c", Labels: map[string]string{"tier": "free"}}}, false},
+	}
+	for _, tc := range namespaces {
+		if got := rule.matchesNamespace(tc.ns); got != tc.want {
+			t.Errorf("matchesNamespace(%s) = %v, want %v", tc.ns.Name, got, tc.want)
+		}
+	}
+
+	issuers := []struct {
+		ref  issuerRef
+		want bool
+	}{
+		{issuerRef{name: "commercial-ca", kind: ClusterIssuerKind}, true},
+		{issuerRef{name: "commercial-ca", kind: ClusterIssuerKind, group: certManagerGroup}, true},
+		{issuerRef{name: "letsencrypt", kind: ClusterIssuerKind}, false},
+		{issuerRef{name: "anything", kind: IssuerKind}, true},
+		{issuerRef{name: "anything", kind: IssuerKind, group: "example.com"}, false},
+	}
+	for _, tc := range issuers {
+		if got := rule.allows(tc.ref); got != tc.want {
+			t.Errorf("allows(%s) = %v, want %v", tc.ref, got, tc.want)
+		}
+	}
+}
+
+func TestIssuerPolicyAllows(t *testing.T) {
+	policy := &IssuerPolicy{
+		Rules: []IssuerPolicyRule{
+			{Namespaces: []string{"team-a"}, Issuers: []IssuerPolicyIssuer{{Kind: ClusterIssuerKind, Name: "commercial-ca"}}},
+			{Namespaces: []string{"team-b"}, Issuers: []IssuerPolicyIssuer{{Kind: IssuerKind, Name: "*"}}},
+		},
+	}
+
+	tests := []struct {
+		ns   string
+		ref  issuerRef
+		want bool
+	}{
+		{"team-a", issuerRef{name: "commercial-ca", kind: ClusterIssuerKind}, true},
+		{"team-a", issuerRef{name: "local", kind: IssuerKind}, false},
+		{"team-b", issuerRef{name: "local", kind: IssuerKind}, true},
+		{"team-b", issuerRef{name: "commercial-ca", kind: ClusterIssuerKind}, false},
+		{"team-c", issuerRef{name: "commercial-ca", kind: ClusterIssuerKind}, false},
+	}
+	for _, tc := range tests {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tc.ns}}
+		if got := policy.allows(ns, tc.ref); got != tc.want {
+			t.Errorf("allows(%s, %s) = %v, want %v", tc.ns, tc.ref, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"testing"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIssuerOf(t *testing.T) {
+	stepIssuer := schema.GroupKind{Group: "certmanager.step.sm", Kind: "StepClusterIssuer"}
+	tests := []struct {
+		name        string
+		r           *HTTPProxyReconciler
+		annotations map[string]string
+		want        issuerRef
+		wantErr     bool
+	}{
+		{
+			name: "Default issuer",
+			r:    &HTTPProxyReconciler{DefaultIssuerName: "default", DefaultIssuerKind: ClusterIssuerKind},
+			want: issuerRef{name: "default", kind: ClusterIssuerKind},
+		},
+		{
+			name: "Default external issuer",
+			r: &HTTPProxyReconciler{
+				DefaultIssuerName:  "step",
+				DefaultIssuerKind:  stepIssuer.Kind,
+				DefaultIssuerGroup: stepIssuer.Group,
+				AllowedIssuerKinds: []schema.GroupKind{stepIssuer},
+			},
+			want: issuerRef{name: "step", kind: stepIssuer.Kind, group: stepIssuer.Group},
+		},
+		{
+			name:        "Issuer annotation",
+			r:           &HTTPProxyReconciler{DefaultIssuerName: "default", DefaultIssuerKind: ClusterIssuerKind},
+			annotations: map[string]string{issuerNameAnnotation: "custom"},
+			want:        issuerRef{name: "custom", kind: IssuerKind},
+		},
+		{
+			name: "Cluster issuer annotation takes precedence",
+			r:    &HTTPProxyReconciler{},
+			annotations: map[string]string{
+				issuerNameAnnotation:        "custom",
+				clusterIssuerNameAnnotation: "cluster",
+			},
+			want: issuerRef{name: "cluster", kind: ClusterIssuerKind},
+		},
+		{
+			name: "External issuer annotations",
+			r:    &HTTPProxyReconciler{AllowedIssuerKinds: []schema.GroupKind{stepIssuer}},
+			annotations: map[string]string{
+				issuerNameAnnotation:  "step",
+				issuerKindAnnotation:  stepIssuer.Kind,
+				issuerGroupAnnotation: stepIssuer.Group,
+			},
+			want: issuerRef{name: "step", kind: stepIssuer.Kind, group: stepIssuer.Group},
+		},
+		{
+			name: "Kind and group annotations without issuer name are ignored",
+			r:    &HTTPProxyReconciler{DefaultIssuerName: "default", DefaultIssuerKind: ClusterIssuerKind},
+			annotations: map[string]string{
+				issuerKindAnnotation:  stepIssuer.Kind,
+				issuerGroupAnnotation: stepIssuer.Group,
+			},
+			want: issuerRef{name: "default", kind: ClusterIssuerKind},
+		},
+		{
+			name: "External issuer not allowed",
+			r:    &HTTPProxyReconciler{},
+			annotations: map[string]string{
+				issuerNameAnnotation:  "vault",
+				issuerKindAnnotation:  "VaultIssuer",
+				issuerGroupAnnotation: "vault.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown kind of cert-manager",
+			r:    &HTTPProxyReconciler{},
+			annotations: map[string]string{
+				issuerNameAnnotation: "foo",
+				issuerKindAnnotation: "FooIssuer",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+			}
+			got, err := tc.r.issuerOf(hp)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("issuerOf() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIssuerRefToMap(t *testing.T) {
+	m := issuerRef{name: "step", kind: "StepIssuer", group: "certmanager.step.sm"}.toMap()
+	if m["group"] != "certmanager.step.sm" || m["kind"] != "StepIssuer" || m["name"] != "step" {
+		t.Errorf("unexpected issuerRef: %v", m)
+	}
+	m = issuerRef{name: "letsencrypt", kind: ClusterIssuerKind}.toMap()
+	if _, ok := m["group"]; ok {
+		t.Errorf("group should be omitted for cert-manager issuers: %v", m)
+	}
+}
+
+func TestIsReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []interface{}
+		want       bool
+	}{
+		{
+			name: "No conditions",
+		},
+		{
+			name: "Ready",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			},
+			want: true,
+		},
+		{
+			name: "Not ready",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "False", "reason": "ErrRegisterACMEAccount"},
+			},
+		},
+		{
+			name: "Other condition",
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Synced", "status": "True"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tc.conditions != nil {
+				obj.Object["status"] = map[string]interface{}{"conditions": tc.conditions}
+			}
+			if got := isReady(obj); got != tc.want {
+				t.Errorf("isReady() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "contour_plus"
+
+var (
+	// hostnameConflicts is the number of HTTPProxies currently losing hostname conflicts
+	hostnameConflicts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "hostname_conflicts",
+		Help:      "Number of HTTPProxies refused because another HTTPProxy uses the same hostname.",
+	})
+
+	losingProxies = &proxySet{proxies: make(map[types.NamespacedName]struct{})}
+)
+
+func init() {
+	metrics.Registry.MustRegister(hostnameConflicts)
+}
+
+// proxySet keeps the HTTPProxies losing hostname conflicts, and exports their number to hostnameConflicts.
+type proxySet struct {
+	mu      sync.Mutex
+	proxies map[types.NamespacedName]struct{}
+}
+
+// set adds the HTTPProxy to the set if losing is true, or removes it otherwise.
+func (s *proxySet) set(key types.NamespacedName, losing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if losing {
+		s.proxies[key] = struct{}{}
+	} else {
+		delete(s.proxies, key)
+	}
+	hostnameConflicts.Set(float64(len(s.proxies)))
+}
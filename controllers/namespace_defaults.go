@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// namespaceDefaultAnnotations lists the annotations of a Namespace inherited by the HTTPProxies in it.
+// The annotations in a group are inherited together, and only if the HTTPProxy has none of them,
+// so that, for example, an issuer-kind annotation of the Namespace is never combined with an issuer of the HTTPProxy.
+var namespaceDefaultAnnotations = [][]string{
+	{excludeAnnotation},
+	{issuerNameAnnotation, clusterIssuerNameAnnotation, issuerKindAnnotation, issuerGroupAnnotation},
+	{delegatedDomainAnnotation},
+	{privateKeyAlgorithmAnnotation, privateKeySizeAnnotation},
+	{revisionHistoryLimitAnnotation},
+	{recordTTLAnnotation},
+	{dnsViewAnnotation},
+	{ipFamiliesAnnotation},
+}
+
+// withNamespaceDefaults returns the HTTPProxy with the default annotations inherited from its Namespace.
+// The HTTPProxy is returned as is if it inherits nothing; otherwise a copy is returned.
+func (r *HTTPProxyReconciler) withNamespaceDefaults(ctx context.Context, hp *projectcontourv1.HTTPProxy) (*projectcontourv1.HTTPProxy, error) {
+	ns := &corev1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: hp.Namespace}, ns)
+	if k8serrors.IsNotFound(err) {
+		return hp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := inheritAnnotations(hp.Annotations, ns.Annotations)
+	if annotations == nil {
+		return hp, nil
+	}
+	hp = hp.DeepCopy()
+	hp.Annotations = annotations
+	return hp, nil
+}
+
+// inheritAnnotations returns the annotations of an HTTPProxy merged with the default annotations of its Namespace,
+// or nil if nothing is inherited.
+func inheritAnnotations(proxyAnnotations, namespaceAnnotations map[string]string) map[string]string {
+	var merged map[string]string
+	for _, group := range namespaceDefaultAnnotations {
+		if hasAnyKey(proxyAnnotations, group) {
+			continue
+		}
+		for _, key := range group {
+			value, ok := namespaceAnnotations[key]
+			if !ok {
+				continue
+			}
+			if merged == nil {
+				merged = maps.Clone(proxyAnnotations)
+				if merged == nil {
+					merged = make(map[string]string)
+				}
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+func hasAnyKey(m map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceRequests returns the requests for the HTTPProxies in the Namespace.
+func (r *HTTPProxyReconciler) namespaceRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.proxyRequests(ctx, obj.GetName())
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInheritAnnotations(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxy     map[string]string
+		namespace map[string]string
+		want      map[string]string
+	}{
+		{
+			name:      "No defaults",
+			proxy:     map[string]string{testACMETLSAnnotation: "true"},
+			namespace: map[string]string{"foo": "bar"},
+			want:      nil,
+		},
+		{
+			name:      "Inherit defaults",
+			proxy:     nil,
+			namespace: map[string]string{excludeAnnotation: "true", delegatedDomainAnnotation: "acme.example.com", "foo": "bar"},
+			want:      map[string]string{excludeAnnotation: "true", delegatedDomainAnnotation: "acme.example.com"},
+		},
+		{
+			name:      "HTTPProxy takes precedence",
+			proxy:     map[string]string{excludeAnnotation: "false", revisionHistoryLimitAnnotation: "1"},
+			namespace: map[string]string{excludeAnnotation: "true", revisionHistoryLimitAnnotation: "3", recordTTLAnnotation: "60"},
+			want:      map[string]string{excludeAnnotation: "false", revisionHistoryLimitAnnotation: "1", recordTTLAnnotation: "60"},
+		},
+		{
+			name:      "Issuer annotations are inherited together",
+			proxy:     map[string]string{issuerNameAnnotation: "proxy-issuer"},
+			namespace: map[string]string{clusterIssuerNameAnnotation: "namespace-issuer", issuerKindAnnotation: "StepClusterIssuer", privateKeyAlgorithmAnnotation: "ECDSA"},
+			want:      map[string]string{issuerNameAnnotation: "proxy-issuer", privateKeyAlgorithmAnnotation: "ECDSA"},
+		},
+		{
+			name:      "Private key annotations are inherited together",
+			proxy:     map[string]string{privateKeyAlgorithmAnnotation: "RSA"},
+			namespace: map[string]string{privateKeyAlgorithmAnnotation: "ECDSA", privateKeySizeAnnotation: "256"},
+			want:      nil,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := inheritAnnotations(tc.proxy, tc.namespace)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("inheritAnnotations() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
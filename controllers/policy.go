@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// policyDefaults returns the defaults given by ReconcilerOptions as a policy.
+func (r *HTTPProxyReconciler) policyDefaults() contourplusv1alpha1.EffectivePolicy {
+	ttl := r.DefaultRecordTTL
+	if ttl == 0 {
+		ttl = defaultRecordTTL
+	}
+	return contourplusv1alpha1.EffectivePolicy{
+		DefaultIssuerName:       r.DefaultIssuerName,
+		DefaultIssuerKind:       r.DefaultIssuerKind,
+		DefaultIssuerGroup:      r.DefaultIssuerGroup,
+		DefaultDelegatedDomain:  r.DefaultDelegatedDomain,
+		AllowedDelegatedDomains: r.AllowedDelegatedDomains,
+		CSRRevisionLimit:        int32(min(r.CSRRevisionLimit, math.MaxInt32)),
+		PropagatedAnnotations:   r.PropagatedAnnotations,
+		PropagatedLabels:        r.PropagatedLabels,
+		DNSRecordTTL:            int32(min(ttl, math.MaxInt32)),
+	}
+}
+
+// forNamespace returns the reconciler whose defaults are overridden by the ContourPlusPolicy of the namespace.
+// The returned reconciler must be used only for the objects generated in the namespace.
+func (r *HTTPProxyReconciler) forNamespace(ctx context.Context, namespace string) (*HTTPProxyReconciler, error) {
+	if !r.EnablePolicies {
+		return r, nil
+	}
+	base := r
+	if r.base != nil {
+		base = r.base
+	}
+
+	effective, _, err := resolvePolicy(ctx, base.Client, namespace, base.policyDefaults())
+	if err != nil {
+		return nil, err
+	}
+
+	nr := *base
+	nr.base = base
+	nr.DefaultIssuerName = effective.DefaultIssuerName
+	nr.DefaultIssuerKind = effective.DefaultIssuerKind
+	nr.DefaultIssuerGroup = effective.DefaultIssuerGroup
+	nr.DefaultDelegatedDomain = effective.DefaultDelegatedDomain
+	nr.AllowedDelegatedDomains = effective.AllowedDelegatedDomains
+	nr.CSRRevisionLimit = uint(effective.CSRRevisionLimit)
+	nr.PropagatedAnnotations = effective.PropagatedAnnotations
+	nr.PropagatedLabels = effective.PropagatedLabels
+	nr.DefaultRecordTTL = uint(effective.DNSRecordTTL)
+	return &nr, nil
+}
+
+// resolvePolicy returns the policy in effect for the namespace, and the fields of its ContourPlusPolicy
+// adjusted to the guardrails of ClusterContourPlusPolicy.
+func resolvePolicy(ctx context.Context, c client.Reader, namespace string, defaults contourplusv1alpha1.EffectivePolicy) (contourplusv1alpha1.EffectivePolicy, []string, error) {
+	policy := &contourplusv1alpha1.ContourPlusPolicy{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: contourplusv1alpha1.PolicyName}, policy)
+	if k8serrors.IsNotFound(err) {
+		return defaults, nil, nil
+	}
+	if err != nil {
+		return contourplusv1alpha1.EffectivePolicy{}, nil, err
+	}
+
+	guardrails, err := clusterGuardrails(ctx, c)
+	if err != nil {
+		return contourplusv1alpha1.EffectivePolicy{}, nil, err
+	}
+	effective, adjusted := mergePolicy(defaults, &policy.Spec, guardrails)
+	return effective, adjusted, nil
+}
+
+// clusterGuardrails returns the spec of the ClusterContourPlusPolicy, or nil if it does not exist.
+func clusterGuardrails(ctx context.Context, c client.Reader) (*contourplusv1alpha1.ClusterContourPlusPolicySpec, error) {
+	policy := &contourplusv1alpha1.ClusterContourPlusPolicy{}
+	err := c.Get(ctx, client.ObjectKey{Name: contourplusv1alpha1.PolicyName}, policy)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy.Spec, nil
+}
+
+// mergePolicy overrides the defaults with the fields set in spec.
+// The fields exceeding the guardrails are adjusted or ignored, and their names are returned.
+func mergePolicy(defaults contourplusv1alpha1.EffectivePolicy, spec *contourplusv1alpha1.ContourPlusPolicySpec, guardrails *contourplusv1alpha1.ClusterContourPlusPolicySpec) (contourplusv1alpha1.EffectivePolicy, []string) {
+	effective := *defaults.DeepCopy()
+	if spec == nil {
+		return effective, nil
+	}
+	if guardrails == nil {
+		guardrails = &contourplusv1alpha1.ClusterContourPlusPolicySpec{}
+	}
+	var adjusted []string
+
+	if spec.DefaultIssuerName != "" {
+		issuer := contourplusv1alpha1.IssuerReference{Name: spec.DefaultIssuerName, Kind: spec.DefaultIssuerKind}
+		if issuer.Kind == "" {
+			issuer.Kind = ClusterIssuerKind
+		}
+		if len(guardrails.AllowedIssuers) == 0 || slices.Contains(guardrails.AllowedIssuers, issuer) {
+			effective.DefaultIssuerName = issuer.Name
+			effective.DefaultIssuerKind = issuer.Kind
+			effective.DefaultIssuerGroup = ""
+		} else {
+			adjusted = append(adjusted, "defaultIssuerName")
+		}
+	}
+
+	if spec.DefaultDelegatedDomain != "" {
+		if len(guardrails.AllowedDelegatedDomains) == 0 || matchesDomainPatterns(guardrails.AllowedDelegatedDomains, spec.DefaultDelegatedDomain) {
+			effective.DefaultDelegatedDomain = spec.DefaultDelegatedDomain
+		} else {
+			adjusted = append(adjusted, "defaultDelegatedDomain")
+		}
+	}
+	if spec.AllowedDelegatedDomains != nil {
+		domains, ok := filterAllowedDomains(spec.AllowedDelegatedDomains, guardrails.AllowedDelegatedDomains)
+		effective.AllowedDelegatedDomains = domains
+		if !ok {
+			adjusted = append(adjusted, "allowedDelegatedDomains")
+		}
+	}
+
+	if spec.CSRRevisionLimit != nil {
+		limit := *spec.CSRRevisionLimit
+		// 0 keeps all CertificateRequests, which exceeds any maximum
+		if maxLimit := guardrails.MaxCSRRevisionLimit; maxLimit != nil && (limit == 0 || limit > *maxLimit) {
+			limit = *maxLimit
+			adjusted = append(adjusted, "csrRevisionLimit")
+		}
+		effective.CSRRevisionLimit = limit
+	}
+
+	if spec.PropagatedAnnotations != nil {
+		keys, ok := filterAllowed(spec.PropagatedAnnotations, guardrails.AllowedPropagatedAnnotations)
+		effective.PropagatedAnnotations = keys
+		if !ok {
+			adjusted = append(adjusted, "propagatedAnnotations")
+		}
+	}
+	if spec.PropagatedLabels != nil {
+		keys, ok := filterAllowed(spec.PropagatedLabels, guardrails.AllowedPropagatedLabels)
+		effective.PropagatedLabels = keys
+		if !ok {
+			adjusted = append(adjusted, "propagatedLabels")
+		}
+	}
+
+	if spec.DNSRecordTTL != nil {
+		ttl := *spec.DNSRecordTTL
+		if minTTL := guardrails.MinDNSRecordTTL; minTTL != nil && ttl < *minTTL {
+			ttl = *minTTL
+		}
+		if maxTTL := guardrails.MaxDNSRecordTTL; maxTTL != nil && ttl > *maxTTL {
+			ttl = *maxTTL
+		}
+		if ttl != *spec.DNSRecordTTL {
+			adjusted = append(adjusted, "dnsRecordTTL")
+		}
+		effective.DNSRecordTTL = ttl
+	}
+	return effective, adjusted
+}
+
+// filterAllowed returns the values contained in allowed, and whether all values are allowed.
+// An empty allowed puts no limit.
+func filterAllowed(values, allowed []string) ([]string, bool) {
+	if len(allowed) == 0 {
+		return values, true
+	}
+	filtered := make([]string, 0, len(values))
+	for _, v := range values {
+		if slices.Contains(allowed, v) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered, len(filtered) == len(values)
+}
+
+// policyRequests returns the requests for the HTTPProxies affected by the ContourPlusPolicy or ClusterContourPlusPolicy.
+func (r *HTTPProxyReconciler) policyRequests(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != contourplusv1alpha1.PolicyName {
+		return nil
+	}
+	// the namespace of ClusterContourPlusPolicy is empty, so all HTTPProxies are listed
+	return r.proxyRequests(ctx, obj.GetNamespace())
+}
+
+// proxyRequests returns the requests for the HTTPProxies in the namespace, or in all namespaces if it is empty.
+func (r *HTTPProxyReconciler) proxyRequests(ctx context.Context, namespace string) []reconcile.Request {
+	var hpList projectcontourv1.HTTPProxyList
+	err := r.List(ctx, &hpList, client.InNamespace(namespace))
+	if err != nil {
+		r.Log.Error(err, "listing HTTPProxy failed")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(hpList.Items))
+	for _, hp := range hpList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: hp.Namespace,
+			Name:      hp.Name,
+		}})
+	}
+	return requests
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+func TestMergePolicy(t *testing.T) {
+	defaults := contourplusv1alpha1.EffectivePolicy{
+		DefaultIssuerName:       "default-issuer",
+		DefaultIssuerKind:       "StepClusterIssuer",
+		DefaultIssuerGroup:      "certmanager.step.sm",
+		DefaultDelegatedDomain:  "acme.example.com",
+		AllowedDelegatedDomains: []string{"acme.example.com"},
+		CSRRevisionLimit:        0,
+		PropagatedAnnotations:   []string{"foo"},
+		DNSRecordTTL:            3600,
+	}
+
+	tests := []struct {
+		name         string
+		spec         *contourplusv1alpha1.ContourPlusPolicySpec
+		guardrails   *contourplusv1alpha1.ClusterContourPlusPolicySpec
+		want         contourplusv1alpha1.EffectivePolicy
+		wantAdjusted []string
+	}{
+		{
+			name: "No policy",
+			want: defaults,
+		},
+		{
+			name: "Override without guardrails",
+			spec: &contourplusv1alpha1.ContourPlusPolicySpec{
+				DefaultIssuerName:       "tenant-issuer",
+				DefaultIssuerKind:       IssuerKind,
+				DefaultDelegatedDomain:  "acme.tenant.example.com",
+				AllowedDelegatedDomains: []string{"acme.tenant.example.com"},
+				CSRRevisionLimit:        ptr.To[int32](3),
+				PropagatedLabels:        []string{"bar"},
+				DNSRecordTTL:            ptr.To[int32](60),
+			},
+			want: contourplusv1alpha1.EffectivePolicy{
+				DefaultIssuerName:       "tenant-issuer",
+				DefaultIssuerKind:       IssuerKind,
+				DefaultDelegatedDomain:  "acme.tenant.example.com",
+				AllowedDelegatedDomains: []string{"acme.tenant.example.com"},
+				CSRRevisionLimit:        3,
+				PropagatedAnnotations:   []string{"foo"},
+				PropagatedLabels:        []string{"bar"},
+				DNSRecordTTL:            60,
+			},
+		},
+		{
+			name: "Issuer kind defaults to ClusterIssuer",
+			spec: &contourplusv1alpha1.ContourPlusPolicySpec{
+				DefaultIssuerName: "tenant-issuer",
+			},
+			guardrails: &contourplusv1alpha1.ClusterContourPlusPolicySpec{
+				AllowedIssuers: []contourplusv1alpha1.IssuerReference{{Name: "tenant-issuer", Kind: ClusterIssuerKind}},
+			},
+			want: contourplusv1alpha1.EffectivePolicy{
+				DefaultIssuerName:       "tenant-issuer",
+				DefaultIssuerKind:       ClusterIssuerKind,
+				DefaultDelegatedDomain:  "acme.example.com",
+				AllowedDelegatedDomains: []string{"acme.example.com"},
+				PropagatedAnnotations:   []string{"foo"},
+				DNSRecordTTL:            3600,
+			},
+		},
+		{
+			name: "Exceed guardrails",
+			spec: &contourplusv1alpha1.ContourPlusPolicySpec{
+				DefaultIssuerName:       "tenant-issuer",
+				DefaultIssuerKind:       IssuerKind,
+				DefaultDelegatedDomain:  "acme.tenant.example.com",
+				AllowedDelegatedDomains: []string{"acme.example.com", "acme.tenant.example.com"},
+				CSRRevisionLimit:        ptr.To[int32](0),
+				PropagatedAnnotations:   []string{"foo", "bar"},
+				PropagatedLabels:        []string{"foo", "bar"},
+				DNSRecordTTL:            ptr.To[int32](10),
+			},
+			guardrails: &contourplusv1alpha1.ClusterContourPlusPolicySpec{
+				AllowedIssuers:               []contourplusv1alpha1.IssuerReference{{Name: "tenant-issuer", Kind: ClusterIssuerKind}},
+				AllowedDelegatedDomains:      []string{"acme.example.com"},
+				MaxCSRRevisionLimit:          ptr.To[int32](5),
+				AllowedPropagatedAnnotations: []string{"foo"},
+				AllowedPropagatedLabels:      []string{"foo", "bar"},
+				MinDNSRecordTTL:              ptr.To[int32](30),
+				MaxDNSRecordTTL:              ptr.To[int32](600),
+			},
+			want: contourplusv1alpha1.EffectivePolicy{
+				DefaultIssuerName:       "default-issuer",
+				DefaultIssuerKind:       "StepClusterIssuer",
+				DefaultIssuerGroup:      "certmanager.step.sm",
+				DefaultDelegatedDomain:  "acme.example.com",
+				AllowedDelegatedDomains: []string{"acme.example.com"},
+				CSRRevisionLimit:        5,
+				PropagatedAnnotations:   []string{"foo"},
+				PropagatedLabels:        []string{"foo", "bar"},
+				DNSRecordTTL:            30,
+			},
+			wantAdjusted: []string{"defaultIssuerName", "defaultDelegatedDomain", "allowedDelegatedDomains", "csrRevisionLimit", "propagatedAnnotations", "dnsRecordTTL"},
+		},
+		{
+			name: "Delegated domains within wildcard guardrails",
+			spec: &contourplusv1alpha1.ContourPlusPolicySpec{
+				DefaultDelegatedDomain:  "team-a.acme.example.com",
+				AllowedDelegatedDomains: []string{"*.team-a.acme.example.com", "acme.example.net"},
+			},
+			guardrails: &contourplusv1alpha1.ClusterContourPlusPolicySpec{
+				AllowedDelegatedDomains: []string{"*.acme.example.com"},
+			},
+			want: contourplusv1alpha1.EffectivePolicy{
+				DefaultIssuerName:       "default-issuer",
+				DefaultIssuerKind:       "StepClusterIssuer",
+				DefaultIssuerGroup:      "certmanager.step.sm",
+				DefaultDelegatedDomain:  "team-a.acme.example.com",
+				AllowedDelegatedDomains: []string{"*.team-a.acme.example.com"},
+				PropagatedAnnotations:   []string{"foo"},
+				DNSRecordTTL:            3600,
+			},
+			wantAdjusted: []string{"allowedDelegatedDomains"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, adjusted := mergePolicy(defaults, tc.spec, tc.guardrails)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("effective policy = %+v, want %+v", got, tc.want)
+			}
+			if !reflect.DeepEqual(adjusted, tc.wantAdjusted) {
+				t.Errorf("adjusted = %v, want %v", adjusted, tc.wantAdjusted)
+			}
+		})
+	}
+}
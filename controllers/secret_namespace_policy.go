@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// SecretNamespacePolicy restricts the namespaces of the HTTPProxies that may refer to TLS secrets
+// in the namespaces listed in AllowedSecretNamespaces.
+type SecretNamespacePolicy struct {
+	// Rules lists the namespaces allowed to refer to the secrets in the secret namespaces of each rule.
+	Rules []SecretNamespacePolicyRule `json:"rules"`
+}
+
+// SecretNamespacePolicyRule allows the namespaces listed in Namespaces or matched by NamespaceSelector
+// to refer to the secrets in SecretNamespaces.
+type SecretNamespacePolicyRule struct {
+	SecretNamespaces  []string              `json:"secretNamespaces"`
+	Namespaces        []string              `json:"namespaces,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// LoadSecretNamespacePolicy reads SecretNamespacePolicy from a YAML file.
+func LoadSecretNamespacePolicy(path string) (*SecretNamespacePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &SecretNamespacePolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid secret namespace policy in %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func (p *SecretNamespacePolicy) validate() error {
+	for i, rule := range p.Rules {
+		if len(rule.SecretNamespaces) == 0 {
+			return fmt.Errorf("rules[%d] must have secretNamespaces", i)
+		}
+		if len(rule.Namespaces) == 0 && rule.NamespaceSelector == nil {
+			return fmt.Errorf("rules[%d] must have namespaces or namespaceSelector", i)
+		}
+		if rule.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(rule.NamespaceSelector); err != nil {
+				return fmt.Errorf("rules[%d] has invalid namespaceSelector: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SecretNamespaces returns the secret namespaces that appear in the rules.
+func (p *SecretNamespacePolicy) SecretNamespaces() []string {
+	var namespaces []string
+	for _, rule := range p.Rules {
+		for _, ns := range rule.SecretNamespaces {
+			if !slices.Contains(namespaces, ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	return namespaces
+}
+
+// allows returns true if the policy allows the HTTPProxies in ns to refer to the secrets in secretNamespace.
+func (p *SecretNamespacePolicy) allows(secretNamespace string, ns *corev1.Namespace) bool {
+	for _, rule := range p.Rules {
+		if slices.Contains(rule.SecretNamespaces, secretNamespace) && namespaceMatches(ns, rule.Namespaces, rule.NamespaceSelector) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSourceNamespaceAllowed returns true if the HTTPProxies in namespace may refer to the TLS secrets in secretNamespace.
+// A secret in another namespace requires secretNamespace to be in AllowedSecretNamespaces and SecretNamespacePolicy
+// to allow namespace. Without SecretNamespacePolicy, no secret in another namespace is allowed.
+func (r *HTTPProxyReconciler) isSourceNamespaceAllowed(ctx context.Context, namespace, secretNamespace string) (bool, error) {
+	if namespace == secretNamespace {
+		return true, nil
+	}
+	if !r.isSecretNamespaceAllowed(secretNamespace) || r.SecretNamespacePolicy == nil {
+		return false, nil
+	}
+
+	ns := &corev1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: namespace}, ns)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return r.SecretNamespacePolicy.allows(secretNamespace, ns), nil
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadSecretNamespacePolicy(t *testing.T) {
+	tests := []struct {
+		name                 string
+		content              string
+		wantErr              bool
+		wantSecretNamespaces []string
+	}{
+		{
+			name: "Valid policy",
+			content: `
+rules:
+- secretNamespaces: [certs]
+  namespaces: [team-a]
+- secretNamespaces: [certs, shared]
+  namespaceSelector:
+    matchLabels:
+      tier: premium
+`,
+			wantSecretNamespaces: []string{"certs", "shared"},
+		},
+		{
+			name: "Rule without secret namespaces",
+			content: `
+rules:
+- namespaces: [team-a]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Rule without namespaces",
+			content: `
+rules:
+- secretNamespaces: [certs]
+`,
+			wantErr: true,
+		},
+		{
+			name: "Invalid selector",
+			content: `
+rules:
+- secretNamespaces: [certs]
+  namespaceSelector:
+    matchExpressions:
+    - key: tier
+      operator: Unknown
+`,
+			wantErr: true,
+		},
+		{
+			name: "Unknown field",
+			content: `
+rule: []
+`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := LoadSecretNamespacePolicy(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := policy.SecretNamespaces(); !slices.Equal(got, tc.wantSecretNamespaces) {
+				t.Errorf("SecretNamespaces() = %v, want %v", got, tc.wantSecretNamespaces)
+			}
+		})
+	}
+}
+
+func TestSecretNamespacePolicyAllows(t *testing.T) {
+	policy := &SecretNamespacePolicy{
+		Rules: []SecretNamespacePolicyRule{
+			{SecretNamespaces: []string{"certs"}, Namespaces: []string{"team-a"}},
+			{
+				SecretNamespaces:  []string{"shared"},
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "premium"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		secretNamespace string
+		ns              *corev1.Namespace
+		want            bool
+	}{
+		{"certs", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, true},
+		{"certs", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"tier": "premium"}}}, false},
+		{"shared", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"tier": "premium"}}}, true},
+		{"shared", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, false},
+		{"other", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, false},
+	}
+	for _, tc := range tests {
+		if got := policy.allows(tc.secretNamespace, tc.ns); got != tc.want {
+			t.Errorf("allows(%s, %s) = %v, want %v", tc.secretNamespace, tc.ns.Name, got, tc.want)
+		}
+	}
+}
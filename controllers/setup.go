@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"time"
+
+	contourplusv1alpha1 "github.com/cybozu-go/contour-plus/api/v1alpha1"
 	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -14,24 +18,46 @@ import (
 // ReconcilerOptions is a set of options for reconcilers
 type ReconcilerOptions struct {
 	ServiceKey              client.ObjectKey
+	ServiceKeys             map[string]client.ObjectKey
 	Prefix                  string
 	DefaultIssuerName       string
 	DefaultIssuerKind       string
+	DefaultIssuerGroup      string
+	AllowedIssuerKinds      []schema.GroupKind
+	VerifyIssuer            bool
+	IssuerPolicy            *IssuerPolicy
 	DefaultDelegatedDomain  string
 	AllowedDelegatedDomains []string
 	AllowCustomDelegations  bool
+	DelegationTargets       *DelegationTargetTemplates
+	AllowedSecretNamespaces []string
+	SecretNamespacePolicy   *SecretNamespacePolicy
 	CSRRevisionLimit        uint
+	DefaultRecordTTL        uint
 	CreateDNSEndpoint       bool
 	CreateCertificate       bool
 	IngressClassName        string
+	IngressClassServiceKeys map[string]client.ObjectKey
+	DNSTargetSource         string
+	InvalidHTTPProxyPolicy  string
+	OwnerID                 string
+	OrphanSweepInterval     time.Duration
+	OrphanSweepDryRun       bool
 	PropagatedAnnotations   []string
 	PropagatedLabels        []string
+	EnablePolicies          bool
+	HostnameArbitration     bool
+	SplitHorizon            bool
+	PrivateServiceKey       client.ObjectKey
+	DNSViewLabel            string
+	IPFamilies              string
 }
 
 // SetupScheme initializes a schema
 func SetupScheme(scm *runtime.Scheme) {
 	utilruntime.Must(clientgoscheme.AddToScheme(scm))
 	utilruntime.Must(projectcontourv1.AddToScheme(scm))
+	utilruntime.Must(contourplusv1alpha1.AddToScheme(scm))
 
 	// +kubebuilder:scaffold:scheme
 }
@@ -42,25 +68,71 @@ func SetupReconciler(mgr manager.Manager, scheme *runtime.Scheme, opts Reconcile
 		Client:                  mgr.GetClient(),
 		Log:                     ctrl.Log.WithName("controllers").WithName("HTTPProxy"),
 		Scheme:                  scheme,
+		Recorder:                mgr.GetEventRecorderFor("contour-plus"),
 		ServiceKey:              opts.ServiceKey,
+		ServiceKeys:             opts.ServiceKeys,
 		Prefix:                  opts.Prefix,
 		DefaultIssuerName:       opts.DefaultIssuerName,
 		DefaultIssuerKind:       opts.DefaultIssuerKind,
+		DefaultIssuerGroup:      opts.DefaultIssuerGroup,
+		AllowedIssuerKinds:      opts.AllowedIssuerKinds,
+		VerifyIssuer:            opts.VerifyIssuer,
+		IssuerPolicy:            opts.IssuerPolicy,
 		DefaultDelegatedDomain:  opts.DefaultDelegatedDomain,
 		AllowedDelegatedDomains: opts.AllowedDelegatedDomains,
 		AllowCustomDelegations:  opts.AllowCustomDelegations,
+		DelegationTargets:       opts.DelegationTargets,
+		AllowedSecretNamespaces: opts.AllowedSecretNamespaces,
+		SecretNamespacePolicy:   opts.SecretNamespacePolicy,
 		CSRRevisionLimit:        opts.CSRRevisionLimit,
+		DefaultRecordTTL:        opts.DefaultRecordTTL,
 		CreateDNSEndpoint:       opts.CreateDNSEndpoint,
 		CreateCertificate:       opts.CreateCertificate,
 		IngressClassName:        opts.IngressClassName,
+		IngressClassServiceKeys: opts.IngressClassServiceKeys,
+		DNSTargetSource:         opts.DNSTargetSource,
+		InvalidHTTPProxyPolicy:  opts.InvalidHTTPProxyPolicy,
+		OwnerID:                 opts.OwnerID,
 		PropagatedAnnotations:   opts.PropagatedAnnotations,
 		PropagatedLabels:        opts.PropagatedLabels,
+		EnablePolicies:          opts.EnablePolicies,
+		HostnameArbitration:     opts.HostnameArbitration,
+		SplitHorizon:            opts.SplitHorizon,
+		PrivateServiceKey:       opts.PrivateServiceKey,
+		DNSViewLabel:            opts.DNSViewLabel,
+		IPFamilies:              opts.IPFamilies,
 	}
 	err := httpProxyReconciler.SetupWithManager(mgr)
 	if err != nil {
 		return err
 	}
 
+	if opts.EnablePolicies {
+		policyReconciler := &ContourPlusPolicyReconciler{
+			Client:   mgr.GetClient(),
+			Defaults: httpProxyReconciler.policyDefaults(),
+		}
+		if err := policyReconciler.SetupWithManager(mgr); err != nil {
+			return err
+		}
+	}
+
+	if opts.OrphanSweepInterval > 0 {
+		sweeper := &OrphanSweeper{
+			Client:            mgr.GetClient(),
+			Reader:            mgr.GetAPIReader(),
+			Log:               ctrl.Log.WithName("sweeper"),
+			OwnerID:           opts.OwnerID,
+			CreateDNSEndpoint: opts.CreateDNSEndpoint,
+			CreateCertificate: opts.CreateCertificate,
+			Interval:          opts.OrphanSweepInterval,
+			DryRun:            opts.OrphanSweepDryRun,
+		}
+		if err := mgr.Add(sweeper); err != nil {
+			return err
+		}
+	}
+
 	// +kubebuilder:scaffold:builder
 	return nil
 }
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OrphanSweeper finds the objects generated by contour-plus that the current configuration would not produce,
+// and deletes or reports them.
+// Objects for an existing HTTPProxy whose kind is enabled are left to the garbage collection of HTTPProxyReconciler.
+type OrphanSweeper struct {
+	client.Client
+	// Reader reads objects directly from the API server so that kinds not watched by the manager can be listed.
+	Reader            client.Reader
+	Log               logr.Logger
+	OwnerID           string
+	CreateDNSEndpoint bool
+	CreateCertificate bool
+	Interval          time.Duration
+	DryRun            bool
+}
+
+// Start runs the sweeper at startup and then periodically until ctx is done.
+func (s *OrphanSweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx); err != nil {
+			s.Log.Error(err, "failed to sweep orphaned resources")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes or reports the orphaned objects once.
+func (s *OrphanSweeper) Sweep(ctx context.Context) error {
+	kinds := []struct {
+		gvk     schema.GroupVersionKind
+		enabled bool
+	}{
+		{gvk: externalDNSGroupVersion.WithKind(DNSEndpointKind), enabled: s.CreateDNSEndpoint},
+		{gvk: certManagerGroupVersion.WithKind(CertificateKind), enabled: s.CreateCertificate},
+	}
+
+	for _, k := range kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(k.gvk.GroupVersion().WithKind(k.gvk.Kind + "List"))
+		err := s.Reader.List(ctx, list)
+		if meta.IsNoMatchError(err) {
+			// the CRD is not installed, so there is nothing to sweep
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !isGeneratedBy(obj, s.ownerID()) {
+				continue
+			}
+
+			reason := "kind is disabled"
+			if k.enabled {
+				reason, err = s.orphanReason(ctx, obj)
+				if err != nil {
+					return err
+				}
+				if reason == "" {
+					continue
+				}
+			}
+
+			log := s.Log.WithValues("kind", k.gvk.Kind, "namespace", obj.GetNamespace(), "name", obj.GetName(), "reason", reason)
+			if s.DryRun {
+				log.Info("found orphaned resource (dry-run)")
+				continue
+			}
+			uid := obj.GetUID()
+			err := s.Delete(ctx, obj, client.Preconditions{UID: &uid})
+			if k8serrors.IsNotFound(err) || k8serrors.IsConflict(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			log.Info("deleted orphaned resource")
+		}
+	}
+	return nil
+}
+
+// orphanReason returns why the object is orphaned, or an empty string if one of its HTTPProxies still exists.
+// A Certificate shared with HTTPProxies in other namespaces also belongs to the HTTPProxies in referringProxiesAnnotation.
+func (s *OrphanSweeper) orphanReason(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+	reason := "not owned by HTTPProxy"
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind != "HTTPProxy" {
+			continue
+		}
+
+		hp := &projectcontourv1.HTTPProxy{}
+		err := s.Reader.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: ref.Name}, hp)
+		if k8serrors.IsNotFound(err) {
+			reason = "HTTPProxy not found"
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if hp.UID != ref.UID {
+			reason = "HTTPProxy not found"
+			continue
+		}
+		return "", nil
+	}
+
+	for _, key := range parseProxyKeys(obj.GetAnnotations()[referringProxiesAnnotation]) {
+		err := s.Reader.Get(ctx, key, &projectcontourv1.HTTPProxy{})
+		if k8serrors.IsNotFound(err) {
+			reason = "HTTPProxy not found"
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return "", nil
+	}
+	return reason, nil
+}
+
+func (s *OrphanSweeper) ownerID() string {
+	if s.OwnerID == "" {
+		return defaultOwnerID
+	}
+	return s.OwnerID
+}
+
+// isGeneratedBy returns true if the object was generated by the contour-plus instance identified by ownerID.
+// Objects generated before the owner label was introduced are identified by the field manager of server-side apply.
+// The field manager is shared by all instances, so such objects are attributed only to the instance with the default owner ID.
+func isGeneratedBy(obj client.Object, ownerID string) bool {
+	if owner, ok := obj.GetLabels()[ownerLabel]; ok {
+		return owner == ownerID
+	}
+	if ownerID != defaultOwnerID {
+		return false
+	}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == fieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+	return false
+}
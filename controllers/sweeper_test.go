@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsGeneratedBy(t *testing.T) {
+	tests := []struct {
+		name          string
+		ownerID       string
+		labels        map[string]string
+		managedFields []metav1.ManagedFieldsEntry
+		want          bool
+	}{
+		{
+			name:    "Owner label matches",
+			ownerID: "instance-a",
+			labels:  map[string]string{ownerLabel: "instance-a"},
+			want:    true,
+		},
+		{
+			name:    "Owner label of another instance",
+			ownerID: "instance-a",
+			labels:  map[string]string{ownerLabel: "instance-b"},
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+			},
+			want: false,
+		},
+		{
+			name:    "Applied by contour-plus without owner label",
+			ownerID: defaultOwnerID,
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+			},
+			want: true,
+		},
+		{
+			name:    "Applied by contour-plus without owner label for non-default owner ID",
+			ownerID: "instance-a",
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+			},
+			want: false,
+		},
+		{
+			name:    "Updated by another manager",
+			ownerID: defaultOwnerID,
+			managedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply},
+				{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate},
+			},
+			want: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := dnsEndpoint()
+			obj.SetLabels(tc.labels)
+			obj.SetManagedFields(tc.managedFields)
+			if got := isGeneratedBy(obj, tc.ownerID); got != tc.want {
+				t.Errorf("isGeneratedBy() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"strings"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+)
+
+// Constants for HTTPProxy status set by Contour
+const (
+	proxyStatusValid    = "valid"
+	proxyStatusInvalid  = "invalid"
+	proxyStatusOrphaned = "orphaned"
+)
+
+// proxyValidity is the validity of an HTTPProxy as judged by Contour
+type proxyValidity int
+
+const (
+	// proxyValidityUnknown means Contour has not processed the current generation of the HTTPProxy yet
+	proxyValidityUnknown proxyValidity = iota
+	proxyValidityValid
+	proxyValidityInvalid
+)
+
+// validityOf returns the validity of the HTTPProxy and the reason why it is not valid.
+func validityOf(hp *projectcontourv1.HTTPProxy) (proxyValidity, string) {
+	var validCond *projectcontourv1.DetailedCondition
+	for i := range hp.Status.Conditions {
+		if hp.Status.Conditions[i].Type == projectcontourv1.ValidConditionType {
+			validCond = &hp.Status.Conditions[i]
+			break
+		}
+	}
+	if validCond != nil && validCond.ObservedGeneration != hp.Generation {
+		return proxyValidityUnknown, "status is not up to date"
+	}
+
+	switch hp.Status.CurrentStatus {
+	case proxyStatusValid:
+		if validCond != nil && validCond.Status != projectcontourv1.ConditionTrue {
+			return proxyValidityInvalid, invalidReason(hp, validCond)
+		}
+		return proxyValidityValid, ""
+	case proxyStatusInvalid, proxyStatusOrphaned:
+		return proxyValidityInvalid, invalidReason(hp, validCond)
+	default:
+		return proxyValidityUnknown, "not reconciled by Contour yet"
+	}
+}
+
+func invalidReason(hp *projectcontourv1.HTTPProxy, validCond *projectcontourv1.DetailedCondition) string {
+	reasons := []string{hp.Status.CurrentStatus}
+	if hp.Status.Description != "" {
+		reasons = append(reasons, hp.Status.Description)
+	}
+	if validCond != nil {
+		for _, e := range validCond.Errors {
+			reasons = append(reasons, e.Reason+": "+e.Message)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"testing"
+
+	projectcontourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidityOf(t *testing.T) {
+	validCondition := func(status projectcontourv1.ConditionStatus, generation int64, errs ...projectcontourv1.SubCondition) projectcontourv1.DetailedCondition {
+		return projectcontourv1.DetailedCondition{
+			Condition: projectcontourv1.Condition{
+				Type:               projectcontourv1.ValidConditionType,
+				Status:             status,
+				ObservedGeneration: generation,
+			},
+			Errors: errs,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		status     projectcontourv1.HTTPProxyStatus
+		want       proxyValidity
+		wantReason string
+	}{
+		{
+			name: "No status",
+			want: proxyValidityUnknown,
+		},
+		{
+			name:   "Not reconciled",
+			status: projectcontourv1.HTTPProxyStatus{CurrentStatus: "NotReconciled"},
+			want:   proxyValidityUnknown,
+		},
+		{
+			name:   "Valid without conditions",
+			status: projectcontourv1.HTTPProxyStatus{CurrentStatus: proxyStatusValid},
+			want:   proxyValidityValid,
+		},
+		{
+			name: "Valid with condition",
+			status: projectcontourv1.HTTPProxyStatus{
+				CurrentStatus: proxyStatusValid,
+				Conditions:    []projectcontourv1.DetailedCondition{validCondition(projectcontourv1.ConditionTrue, 2)},
+			},
+			want: proxyValidityValid,
+		},
+		{
+			name: "Condition of older generation",
+			status: projectcontourv1.HTTPProxyStatus{
+				CurrentStatus: proxyStatusValid,
+				Conditions:    []projectcontourv1.DetailedCondition{validCondition(projectcontourv1.ConditionTrue, 1)},
+			},
+			want: proxyValidityUnknown,
+		},
+		{
+			name: "Invalid",
+			status: projectcontourv1.HTTPProxyStatus{
+				CurrentStatus: proxyStatusInvalid,
+				Description:   "At least one error present, see Errors for details",
+				Conditions: []projectcontourv1.DetailedCondition{
+					validCondition(projectcontourv1.ConditionFalse, 2, projectcontourv1.SubCondition{
+						Type:    "VirtualHostError",
+						Reason:  "DuplicateVhost",
+						Message: "fqdn is used in multiple HTTPProxies",
+					}),
+				},
+			},
+			want:       proxyValidityInvalid,
+			wantReason: "invalid; At least one error present, see Errors for details; DuplicateVhost: fqdn is used in multiple HTTPProxies",
+		},
+		{
+			name:       "Orphaned",
+			status:     projectcontourv1.HTTPProxyStatus{CurrentStatus: proxyStatusOrphaned},
+			want:       proxyValidityInvalid,
+			wantReason: "orphaned",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hp := &projectcontourv1.HTTPProxy{
+				ObjectMeta: v1.ObjectMeta{Generation: 2},
+				Status:     tc.status,
+			}
+			got, reason := validityOf(hp)
+			if got != tc.want {
+				t.Errorf("validityOf() = %v, want %v", got, tc.want)
+			}
+			if tc.want == proxyValidityInvalid && reason != tc.wantReason {
+				t.Errorf("validityOf() reason = %q, want %q", reason, tc.wantReason)
+			}
+		})
+	}
+}